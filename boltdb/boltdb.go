@@ -1,9 +1,11 @@
 package boltdb
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/janse180/modem-scraper/config"
 	"github.com/janse180/modem-scraper/scrape"
@@ -15,9 +17,9 @@ import (
 
 // PruneEventLogs queries BoltDB for matching logs and removes them from
 // ModemInformation if found
-func PruneEventLogs(config config.BoltDB, modemInformation scrape.ModemInformation) (*scrape.ModemInformation, error) {
+func PruneEventLogs(ctx context.Context, config config.BoltDB, modemInformation scrape.ModemInformation) (*scrape.ModemInformation, error) {
 
-	db, err := bolt.Open(config.Path, 0600, nil)
+	db, err := openDB(ctx, config.Path)
 	if err != nil {
 		return &modemInformation, fmt.Errorf("error opening BoltDB at %s: %s", config.Path, err.Error())
 	}
@@ -36,8 +38,11 @@ func PruneEventLogs(config config.BoltDB, modemInformation scrape.ModemInformati
 
 	var newEventLog []scrape.EventLog
 	for _, log := range modemInformation.EventLog {
+		if ctx.Err() != nil {
+			return &modemInformation, ctx.Err()
+		}
 		hash := HashLog(log)
-		if !AlreadyLogged(db, log.DateTime, hash) {
+		if !AlreadyLogged(db, log.DateTimeString(), hash) {
 			newEventLog = append(newEventLog, log)
 		}
 	}
@@ -48,9 +53,9 @@ func PruneEventLogs(config config.BoltDB, modemInformation scrape.ModemInformati
 
 // UpdateEventLogs queries BoltDB to write in the record of logs that have been
 // successfully written to InfluxDB and/or MQTT so that we do not rewrite later
-func UpdateEventLogs(logger *zap.Logger, config config.BoltDB, modemInformation scrape.ModemInformation) error {
+func UpdateEventLogs(ctx context.Context, logger *zap.Logger, config config.BoltDB, modemInformation scrape.ModemInformation) error {
 
-	db, err := bolt.Open(config.Path, 0600, nil)
+	db, err := openDB(ctx, config.Path)
 	if err != nil {
 		return fmt.Errorf("error opening BoltDB at %s: %s", config.Path, err.Error())
 	}
@@ -73,6 +78,9 @@ func UpdateEventLogs(logger *zap.Logger, config config.BoltDB, modemInformation
 		return err
 	}
 
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	db.Batch(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("EventLogs"))
 		for dateTime, hashes := range hashMap {
@@ -95,13 +103,69 @@ func UpdateEventLogs(logger *zap.Logger, config config.BoltDB, modemInformation
 	return nil
 }
 
+// Publisher records the event logs which have been published
+// in BoltDB, as a publish.Publisher.
+type Publisher struct {
+	logger *zap.Logger
+	config config.BoltDB
+}
+
+// NewPublisher creates a Publisher for the given BoltDB configuration.
+func NewPublisher(logger *zap.Logger, config config.BoltDB) *Publisher {
+	return &Publisher{
+		logger: logger,
+		config: config,
+	}
+}
+
+// Name returns "boltdb".
+func (p *Publisher) Name() string {
+	return "boltdb"
+}
+
+// Publish records the event logs in modemInformation that
+// are not yet in BoltDB.
+func (p *Publisher) Publish(ctx context.Context, modemInformation scrape.ModemInformation) error {
+	newModemInformation, err := PruneEventLogs(ctx, p.config, modemInformation)
+	if err != nil {
+		return fmt.Errorf("failed to prune event logs: %s", err.Error())
+	}
+
+	err = UpdateEventLogs(ctx, p.logger, p.config, *newModemInformation)
+	if err != nil {
+		return fmt.Errorf("failed to update event logs: %s", err.Error())
+	}
+
+	return nil
+}
+
+// Close is a no-op, the database is opened for every Publish.
+func (p *Publisher) Close() error {
+	return nil
+}
+
+// openDB opens the BoltDB at path, waiting for its file lock
+// until ctx is done rather than forever.
+func openDB(ctx context.Context, path string) (*bolt.DB, error) {
+	options := &bolt.Options{}
+	if deadline, ok := ctx.Deadline(); ok {
+		options.Timeout = time.Until(deadline)
+		if options.Timeout <= 0 {
+			return nil, ctx.Err()
+		}
+	}
+
+	return bolt.Open(path, 0600, options)
+}
+
 func GetUniqueDateTimes(e []scrape.EventLog) []string {
 	dateTimes := []string{}
 	keys := make(map[string]bool)
 	for _, entry := range e {
-		if _, value := keys[entry.DateTime]; !value {
-			keys[entry.DateTime] = true
-			dateTimes = append(dateTimes, entry.DateTime)
+		dateTime := entry.DateTimeString()
+		if _, value := keys[dateTime]; !value {
+			keys[dateTime] = true
+			dateTimes = append(dateTimes, dateTime)
 		}
 	}
 
@@ -114,7 +178,7 @@ func ArrangeHashes(e []scrape.EventLog) map[string][]string {
 
 	for _, dateTime := range dateTimes {
 		for _, log := range e {
-			if log.DateTime == dateTime {
+			if log.DateTimeString() == dateTime {
 				hash := HashLog(log)
 				if !ElementOf(hashMap[dateTime], hash) {
 					hashMap[dateTime] = append(hashMap[dateTime], hash)
@@ -128,7 +192,7 @@ func ArrangeHashes(e []scrape.EventLog) map[string][]string {
 }
 
 func HashLog(log scrape.EventLog) string {
-	logConcat := log.DateTime + strconv.Itoa(log.EventID) + strconv.Itoa(log.EventLevel) + log.Description
+	logConcat := log.DateTimeString() + strconv.Itoa(log.EventID) + strconv.Itoa(log.EventLevel) + log.Description
 	logConcatHash := strconv.FormatUint(xxhash.Checksum64([]byte(logConcat)), 16)
 	return logConcatHash
 }
@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 // Configuration holds all configuration for modem-scraper.
 type Configuration struct {
 	Modem      Modem
@@ -8,39 +10,148 @@ type Configuration struct {
 	InfluxDB   InfluxDB
 	BoltDB     BoltDB
 	Prometheus Prometheus
+	Timeouts   Timeouts
+	Redaction  Redaction
+	Log        Log
 }
 
 // Modem holds modem configuration
 type Modem struct {
+	Model    string
 	Url      string
 	Username string
 	Password string
+	// LoginPerPoll logs in and out on every poll, rather than
+	// reusing one login until the modem expires it.
+	LoginPerPoll bool
+	// Timeout bounds each request to the modem, including
+	// connecting and the TLS handshake. Defaults to 30s.
+	Timeout time.Duration
+	// CertificateFingerprint pins the modem's self-signed
+	// certificate by its SHA-256 fingerprint, in hex.
+	CertificateFingerprint string
+	// Attempts is how many times each page is fetched before
+	// its section is given up on. Defaults to 3.
+	Attempts int
+	// RetryBackoff is the delay before the first retry, doubling
+	// for each retry after that. Defaults to 1s.
+	RetryBackoff time.Duration
+	// Parallelism is how many pages are fetched at once. Defaults
+	// to 1, as some firmware can't cope with concurrent requests.
+	Parallelism int
+	// Timezone is the IANA zone the modem's clock is in, e.g.
+	// "America/New_York", for its event log times. Defaults to the
+	// local zone.
+	Timezone string
 }
 
 // Polling holds polling configuration
 type Polling struct {
 	Schedule string
+	// ParallelPublishers publishes to every enabled output at
+	// once, rather than one after the other.
+	ParallelPublishers bool
+}
+
+// Timeouts holds the time limits for each stage of a poll.
+type Timeouts struct {
+	// Scrape bounds scraping the modem, including retries.
+	// Defaults to 2m.
+	Scrape time.Duration
+	// Publish bounds each publisher. Defaults to 30s.
+	Publish time.Duration
+	// Shutdown is how long a poll in flight is given to wind
+	// down once modem-scraper is told to stop. Defaults to 30s.
+	Shutdown time.Duration
+}
+
+// Redaction holds how identifiers are redacted from scraped data
+// before it is published. Each mode is "none" (the default),
+// "hash" or "mask".
+type Redaction struct {
+	// MACAddress is the modem's MAC address, in the software
+	// information and as CM-MAC in event log descriptions.
+	MACAddress string
+	// CMTSMAC is the CMTS MAC address in event log descriptions.
+	CMTSMAC string
+	// SerialNumber is the modem's serial number.
+	SerialNumber string
+	// Salt is mixed into hashes, so that they can't be reversed
+	// by hashing every possible serial number or MAC address.
+	Salt string
+}
+
+// Log holds logging configuration.
+type Log struct {
+	// Level is the minimum level logged: debug, info (the
+	// default), warn or error.
+	Level string
+	// Format is "json" (the default) or "console".
+	Format string
 }
 
 // MQTT holds MQTT connection configuration.
 type MQTT struct {
-	Enabled  bool
+	Enabled bool
+	// Broker is the broker URL, e.g. tcp://localhost:1883, or
+	// ssl:// or mqtts:// for TLS. Overrides Hostname and Port.
+	Broker   string
 	Hostname string
 	Port     string
 	Username string
 	Password string
 	Topic    string
 	ClientID string
+	// QoS is the quality of service published at, 0, 1 or 2.
+	QoS int
+	// Retain publishes retained messages, so that subscribers
+	// get the last poll straight away.
+	Retain bool
+	// AvailabilityTopic is set to "online" while connected, and
+	// to "offline" by the broker's Last Will when the connection
+	// is lost. Defaults to <Topic>/availability.
+	AvailabilityTopic string
+	// HomeAssistantDiscovery publishes Home Assistant MQTT discovery
+	// configs for the modem's sensors, under DiscoveryPrefix
+	// (defaults to "homeassistant").
+	HomeAssistantDiscovery bool
+	DiscoveryPrefix        string
+	// TopicTree also publishes every value on a topic of its own,
+	// e.g. <Topic>/downstream/<ChannelID>/snr_db, and each new
+	// event log entry on <Topic>/events.
+	TopicTree bool
+	// CommandTopic, if set, is subscribed to for commands: "scrape"
+	// polls the modem straight away, and "reboot" restarts it.
+	CommandTopic string
+	// CACert, ClientCert and ClientKey are PEM file paths for TLS.
+	CACert        string
+	ClientCert    string
+	ClientKey     string
+	SkipVerifySsl bool
 }
 
 // InfluxDB holds InfluxDB connection configuration.
 type InfluxDB struct {
-	Enabled       bool
+	Enabled bool
+	// Version selects the write API: 1 (the default) for InfluxDB
+	// 1.x, or 2 for the /api/v2/write API of InfluxDB 2.x and 3.x.
+	Version       int
 	Url           string
 	Database      string
 	Username      string
 	Password      string
+	Org           string
+	Bucket        string
+	Token         string
 	SkipVerifySsl bool
+	// Schema selects the point layout: 1 (the default) for the
+	// original layout, or 2 to store categorical values as tags and
+	// statuses as numeric *_ok fields.
+	Schema int
+	// EventTimestamp is "event" to timestamp event log points with
+	// when the modem logged them, or "poll" for when they were
+	// scraped. Defaults to "poll" for schema 1 and "event" for 2.
+	EventTimestamp string
 }
 
 // BoltDB holds BoltDB configuration.
@@ -49,6 +160,18 @@ type BoltDB struct {
 	Path    string
 }
 
+// Prometheus holds Prometheus exporter configuration.
 type Prometheus struct {
-	Enabled bool
+	Enabled       bool
+	ListenAddress string
+	Path          string
+	// WebConfigFile is the path to an exporter-toolkit style web
+	// configuration file, enabling TLS and/or basic auth.
+	WebConfigFile string
+	// ScrapeOnDemand scrapes the modem whenever /metrics is
+	// requested, instead of exporting the last polled data.
+	ScrapeOnDemand bool
+	// MinScrapeInterval is how long an on-demand scrape is reused
+	// for before the modem is scraped again.
+	MinScrapeInterval time.Duration
 }
@@ -1,31 +1,85 @@
 package influxdb
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	_ "github.com/influxdata/influxdb1-client" // this is important because of a bug in go mod
 	client "github.com/influxdata/influxdb1-client/v2"
 	"github.com/janse180/modem-scraper/config"
+	"github.com/janse180/modem-scraper/logging"
 	"github.com/janse180/modem-scraper/scrape"
 	"go.uber.org/zap"
 )
 
 // Publish publishes the data within modemInformation to
 // the InfluxDB server configuration within the given
-// configuration.
-func Publish(logger *zap.Logger, config config.InfluxDB, modemInformation scrape.ModemInformation) error {
+// configuration, giving up when ctx is done.
+func Publish(ctx context.Context, logger *zap.Logger, config config.InfluxDB, modemInformation scrape.ModemInformation) error {
+	return publish(ctx, logger, config, modemInformation, nil)
+}
+
+// publish publishes modemInformation, placing events logged before
+// the modem's clock was set at the boot time from bootClock, if
+// there is one, so that they are written at the same time each poll.
+func publish(ctx context.Context, logger *zap.Logger, config config.InfluxDB, modemInformation scrape.ModemInformation, bootClock *scrape.BootClock) error {
 	start := time.Now()
 
-	logger.Debug(fmt.Sprintf("connecting to InfluxDB server %s", config.Url),
+	options := scrape.InfluxOptions{
+		Schema:         config.Schema,
+		Time:           start,
+		EventTimestamp: config.EventTimestamp,
+	}
+	if bootClock != nil {
+		options.BootTime = bootClock.BootTime(start, modemInformation)
+	}
+	points, err := modemInformation.ToInfluxPoints(options)
+	if err != nil {
+		return err
+	}
+
+	switch config.Version {
+	case 0, 1:
+		err = writeV1(ctx, logger, config, points)
+	case 2:
+		err = writeV2(ctx, logger, config, points)
+	default:
+		err = fmt.Errorf("unsupported InfluxDB version %d", config.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	elapsed := time.Since(start)
+	logger.Debug(fmt.Sprintf("finished writing to InfluxDB, took %s", elapsed),
 		zap.String("op", "influxdb.Publish"),
 	)
 
+	return nil
+}
+
+// writeV1 writes points to an InfluxDB 1.x database.
+func writeV1(ctx context.Context, logger *zap.Logger, config config.InfluxDB, points []*client.Point) error {
+	logger.Debug(fmt.Sprintf("connecting to InfluxDB server %s", logging.URL(config.Url)),
+		zap.String("op", "influxdb.writeV1"),
+	)
+
+	// The client doesn't take a context, so its timeout is set from
+	// the deadline, and the write is abandoned on cancellation.
+	var timeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+		if timeout <= 0 {
+			return ctx.Err()
+		}
+	}
 	influx, err := client.NewHTTPClient(client.HTTPConfig{
 		Addr:               config.Url,
 		Username:           config.Username,
 		Password:           config.Password,
 		InsecureSkipVerify: config.SkipVerifySsl,
+		Timeout:            timeout,
 	})
 	if err != nil {
 		return fmt.Errorf("error creating InfluxDB client: %s", err.Error())
@@ -36,24 +90,64 @@ func Publish(logger *zap.Logger, config config.InfluxDB, modemInformation scrape
 		Database:  config.Database,
 		Precision: "ns",
 	})
-	points, err := modemInformation.ToInfluxPoints()
-	if err != nil {
-		return err
-	}
 	batchPoints.AddPoints(points)
 
 	logger.Debug(fmt.Sprintf("writing %d data points to InfluxDB database %s", len(points), config.Database),
-		zap.String("op", "influxdb.Publish"),
+		zap.String("op", "influxdb.writeV1"),
 	)
-	err = influx.Write(batchPoints)
+	written := make(chan error, 1)
+	go func() { written <- influx.Write(batchPoints) }()
+	select {
+	case err = <-written:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
 	if err != nil {
 		return fmt.Errorf("error writing data to InfluxDB: %s", err.Error())
 	}
 
-	elapsed := time.Since(start)
-	logger.Debug(fmt.Sprintf("finished writing to InfluxDB, took %s", elapsed),
-		zap.String("op", "influxdb.Publish"),
-	)
+	return nil
+}
+
+// Publisher publishes to InfluxDB as a publish.Publisher.
+type Publisher struct {
+	logger    *zap.Logger
+	config    config.InfluxDB
+	bootClock *scrape.BootClock
+}
+
+// NewPublisher creates a Publisher for the given InfluxDB configuration.
+func NewPublisher(logger *zap.Logger, config config.InfluxDB) (*Publisher, error) {
+	if config.Version < 0 || config.Version > 2 {
+		return nil, fmt.Errorf("unsupported InfluxDB version %d", config.Version)
+	}
+	if config.Schema < 0 || config.Schema > scrape.InfluxSchemaV2 {
+		return nil, fmt.Errorf("unsupported InfluxDB schema %d", config.Schema)
+	}
+	switch config.EventTimestamp {
+	case "", scrape.EventTimestampEvent, scrape.EventTimestampPoll:
+	default:
+		return nil, fmt.Errorf("unsupported InfluxDB event timestamp %q", config.EventTimestamp)
+	}
+
+	return &Publisher{
+		logger:    logger,
+		config:    config,
+		bootClock: &scrape.BootClock{},
+	}, nil
+}
+
+// Name returns "influxdb".
+func (p *Publisher) Name() string {
+	return "influxdb"
+}
+
+// Publish publishes modemInformation to InfluxDB.
+func (p *Publisher) Publish(ctx context.Context, modemInformation scrape.ModemInformation) error {
+	return publish(ctx, p.logger, p.config, modemInformation, p.bootClock)
+}
 
+// Close is a no-op, a new client is used for every Publish.
+func (p *Publisher) Close() error {
 	return nil
 }
@@ -0,0 +1,128 @@
+package influxdb
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+	"github.com/janse180/modem-scraper/config"
+	"github.com/janse180/modem-scraper/scrape"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestEncodeLineProtocol(t *testing.T) {
+	timestamp := time.Unix(1500000000, 0)
+	point, err := client.NewPoint(
+		"event log",
+		map[string]string{"b": "x y", "a": "1,2", "empty": ""},
+		map[string]interface{}{
+			"description": `said "hi" \ bye`,
+			"level":       3,
+			"power":       4.5,
+			"ok":          true,
+		},
+		timestamp,
+	)
+	assert.NoError(t, err)
+	actual, err := encodeLineProtocol([]*client.Point{point})
+	assert.NoError(t, err)
+	assert.Equal(t, `event\ log,a=1\,2,b=x\ y description="said \"hi\" \\ bye",level=3i,ok=true,power=4.5 1500000000000000000`+"\n", string(actual))
+}
+
+func TestPublishV2(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/api/v2/write", r.URL.Path)
+		assert.Equal(t, "home", r.URL.Query().Get("org"))
+		assert.Equal(t, "modem", r.URL.Query().Get("bucket"))
+		assert.Equal(t, "ns", r.URL.Query().Get("precision"))
+		if r.Header.Get("Authorization") != "Token s3cr3t" {
+			http.Error(w, `{"code":"unauthorized","message":"unauthorized access"}`, http.StatusUnauthorized)
+			return
+		}
+
+		content, _ := ioutil.ReadAll(r.Body)
+		body = string(content)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	conf := config.InfluxDB{Version: 2, Url: server.URL, Org: "home", Bucket: "modem", Token: "s3cr3t"}
+	modemInformation := scrape.ModemInformation{
+		ConnectionStatus: scrape.ConnectionStatus{
+			DownstreamBondedChannels: []scrape.DownstreamBondedChannel{
+				{ChannelID: 17, LockStatus: "Locked", Modulation: "QAM256", FrequencyHz: 507000000, PowerdBmV: 5.3, SNRdB: 38.2},
+			},
+		},
+		SoftwareInformation: scrape.SoftwareInformation{SoftwareVersion: "1.0", UptimeMins: 60, UptimeString: "0 days 01h:00m:31s.00"},
+	}
+
+	err := Publish(context.Background(), zap.NewNop(), conf, modemInformation)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	assert.NotEmpty(t, lines)
+	fields := map[string]map[string]string{}
+	for _, line := range lines {
+		// measurement[,tags] fields timestamp
+		parts := splitLineProtocol(line, ' ')
+		if !assert.Len(t, parts, 3, line) {
+			continue
+		}
+		fields[parts[0]] = map[string]string{}
+		for _, field := range splitLineProtocol(parts[1], ',') {
+			keyValue := strings.SplitN(field, "=", 2)
+			if assert.Len(t, keyValue, 2, line) {
+				fields[parts[0]][keyValue[0]] = keyValue[1]
+			}
+		}
+	}
+	assert.Equal(t, "5.3", fields["downstream_bonded_channel,channel_id=17"]["power_dbmv"])
+	assert.Equal(t, `"QAM256"`, fields["downstream_bonded_channel,channel_id=17"]["modulation"])
+	assert.Equal(t, `"0 days 01h:00m:31s.00"`, fields["software_information"]["uptime_string"])
+
+	conf.Token = "wrong"
+	err = Publish(context.Background(), zap.NewNop(), conf, modemInformation)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unauthorized access")
+}
+
+func TestNewPublisherValidatesVersion(t *testing.T) {
+	_, err := NewPublisher(zap.NewNop(), config.InfluxDB{Version: 3})
+	assert.Error(t, err)
+
+	_, err = NewPublisher(zap.NewNop(), config.InfluxDB{Version: 2})
+	assert.NoError(t, err)
+}
+
+// splitLineProtocol splits a line of line protocol, or its fields,
+// on sep, except where sep is escaped or within a quoted string.
+func splitLineProtocol(line string, sep rune) []string {
+	parts := []string{}
+	part := strings.Builder{}
+	quoted, escaped := false, false
+	for _, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted:
+			parts = append(parts, part.String())
+			part.Reset()
+			continue
+		}
+		part.WriteRune(r)
+	}
+
+	return append(parts, part.String())
+}
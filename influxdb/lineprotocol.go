@@ -0,0 +1,97 @@
+package influxdb
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	stringEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// encodeLineProtocol encodes points as InfluxDB line protocol, one
+// point per line, with nanosecond timestamps, see
+// https://docs.influxdata.com/influxdb/v2/reference/syntax/line-protocol/
+func encodeLineProtocol(points []*client.Point) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, point := range points {
+		fields, err := point.Fields()
+		if err != nil {
+			return nil, fmt.Errorf("error reading fields of %s: %s", point.Name(), err.Error())
+		}
+
+		buf.WriteString(measurementEscaper.Replace(point.Name()))
+
+		tags := point.Tags()
+		for _, key := range sortedKeys(tags) {
+			// A tag without a value can't be written.
+			if tags[key] == "" {
+				continue
+			}
+			buf.WriteByte(',')
+			buf.WriteString(keyEscaper.Replace(key))
+			buf.WriteByte('=')
+			buf.WriteString(keyEscaper.Replace(tags[key]))
+		}
+
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			if i == 0 {
+				buf.WriteByte(' ')
+			} else {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(keyEscaper.Replace(key))
+			buf.WriteByte('=')
+			value, err := encodeFieldValue(fields[key])
+			if err != nil {
+				return nil, fmt.Errorf("error encoding field %s of %s: %s", key, point.Name(), err.Error())
+			}
+			buf.WriteString(value)
+		}
+
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(point.UnixNano(), 10))
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+func encodeFieldValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int64:
+		return strconv.FormatInt(v, 10) + "i", nil
+	case uint64:
+		return strconv.FormatUint(v, 10) + "u", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case string:
+		return `"` + stringEscaper.Replace(v) + `"`, nil
+	default:
+		return "", fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
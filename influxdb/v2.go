@@ -0,0 +1,66 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+	"github.com/janse180/modem-scraper/config"
+	"go.uber.org/zap"
+)
+
+// writeV2 writes points as line protocol to the /api/v2/write API,
+// which is served by InfluxDB 2.x, and by 3.x for compatibility.
+func writeV2(ctx context.Context, logger *zap.Logger, config config.InfluxDB, points []*client.Point) error {
+	body, err := encodeLineProtocol(points)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("org", config.Org)
+	query.Set("bucket", config.Bucket)
+	query.Set("precision", "ns")
+	address := strings.TrimRight(config.Url, "/") + "/api/v2/write?" + query.Encode()
+
+	req, err := http.NewRequest("POST", address, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating InfluxDB request: %s", err.Error())
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if config.Token != "" {
+		req.Header.Set("Authorization", "Token "+config.Token)
+	}
+
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: config.SkipVerifySsl},
+		},
+	}
+	defer httpClient.CloseIdleConnections()
+
+	logger.Debug(fmt.Sprintf("writing %d data points to InfluxDB bucket %s", len(points), config.Bucket),
+		zap.String("op", "influxdb.writeV2"),
+	)
+	resp, err := httpClient.Do(req)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("error writing data to InfluxDB: %s", err.Error())
+	}
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("error writing data to InfluxDB: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
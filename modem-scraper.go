@@ -1,24 +1,36 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/janse180/modem-scraper/boltdb"
 	"github.com/janse180/modem-scraper/config"
 	"github.com/janse180/modem-scraper/influxdb"
+	"github.com/janse180/modem-scraper/logging"
 	"github.com/janse180/modem-scraper/mqtt"
 	"github.com/janse180/modem-scraper/prom"
+	"github.com/janse180/modem-scraper/publish"
+	"github.com/janse180/modem-scraper/redact"
 	"github.com/janse180/modem-scraper/scrape"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/robfig/cron"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
+const (
+	defaultScrapeTimeout   = 2 * time.Minute
+	defaultPublishTimeout  = 30 * time.Second
+	defaultShutdownTimeout = 30 * time.Second
+)
+
 // BuildVersion is the version of the binary, and is set with ldflags at build time.
 var BuildVersion = "UNKNOWN"
 
@@ -31,12 +43,13 @@ type CliInputs struct {
 
 func main() {
 
-	logger, err := zap.NewDevelopment()
+	// The configuration hasn't been read yet, so log with the
+	// defaults until it has.
+	logger, err := logging.New(config.Log{})
 	if err != nil {
 		fmt.Println("{\"op\": \"main\", \"level\": \"fatal\", \"msg\": \"failed to initiate logger\"}")
 		panic(err)
 	}
-	defer logger.Sync()
 
 	cliInputs := CliInputs{
 		BuildVersion: BuildVersion,
@@ -60,92 +73,238 @@ func main() {
 		panic(err)
 	}
 
-	if configuration.Prometheus.Enabled {
-		go func() {
-			http.Handle("/metrics", promhttp.Handler())
-			http.ListenAndServe(":2112", nil)
-		}()
+	configuredLogger, err := logging.New(configuration.Log)
+	if err != nil {
+		logger.Fatal("failed to set up logging",
+			zap.String("op", "main"),
+			zap.Error(err),
+		)
 	}
+	logger = configuredLogger
+	defer logger.Sync()
 
-	c := cron.New()
-	c.AddFunc(configuration.Polling.Schedule, func() {
-		logger.Debug("waking up",
+	session, err := scrape.NewSession(logger, configuration.Modem)
+	if err != nil {
+		logger.Fatal("failed to set up modem session",
+			zap.String("op", "main"),
+			zap.Error(err),
+		)
+	}
+
+	redactor, err := redact.New(configuration.Redaction)
+	if err != nil {
+		logger.Fatal("failed to set up redaction",
 			zap.String("op", "main"),
+			zap.Error(err),
 		)
-		modemInformation, err := scrape.Scrape(logger, *configuration)
+	}
+
+	scrapeTimeout := durationOrDefault(configuration.Timeouts.Scrape, defaultScrapeTimeout)
+	publishTimeout := durationOrDefault(configuration.Timeouts.Publish, defaultPublishTimeout)
+	shutdownTimeout := durationOrDefault(configuration.Timeouts.Shutdown, defaultShutdownTimeout)
+
+	// ctx is cancelled on shutdown, abandoning any poll in flight.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	scraperMetrics := prom.NewScraperMetrics()
+	promCollector := prom.NewCollector()
+	if configuration.Prometheus.ScrapeOnDemand {
+		promCollector = prom.NewOnDemandCollector(logger, func(collectCtx context.Context) (*scrape.ModemInformation, error) {
+			collectCtx, cancelScrape := context.WithTimeout(collectCtx, scrapeTimeout)
+			defer cancelScrape()
+			modemInformation, err := session.Scrape(collectCtx)
+			if modemInformation != nil {
+				redacted := redactor.ModemInformation(*modemInformation)
+				modemInformation = &redacted
+			}
+			return modemInformation, err
+		}, configuration.Prometheus.MinScrapeInterval)
+	}
+	var server *prom.Server
+	if configuration.Prometheus.Enabled {
+		prometheus.MustRegister(promCollector)
+		prometheus.MustRegister(scraperMetrics)
+		scrape.SetObserver(scraperMetrics)
+		server, err = prom.Listen(logger, configuration.Prometheus, prometheus.DefaultGatherer)
 		if err != nil {
-			logger.Error("failed to scrape modem information",
+			logger.Fatal("failed to start Prometheus exporter",
 				zap.String("op", "main"),
 				zap.Error(err),
 			)
-			return
 		}
-
-		if configuration.Prometheus.Enabled {
-			err = prom.Publish(logger, *modemInformation)
+		go func() {
+			err := server.Serve()
 			if err != nil {
-				logger.Error("failed to write data to Prometheus",
+				logger.Fatal("Prometheus exporter stopped",
 					zap.String("op", "main"),
 					zap.Error(err),
 				)
-				return
 			}
-		}
+		}()
+	}
 
-		if configuration.InfluxDB.Enabled {
-			err = influxdb.Publish(logger, configuration.InfluxDB, *modemInformation)
-			if err != nil {
-				logger.Error("failed to write data to InfluxDB",
-					zap.String("op", "main"),
-					zap.Error(err),
-				)
-				return
-			}
+	publishers := publish.NewRegistry(logger, configuration.Polling.ParallelPublishers, publishTimeout)
+
+	// polling holds a slot while a poll runs, so that shutdown can
+	// wait for it, and so that a slow poll isn't overlapped.
+	polling := make(chan struct{}, 1)
+
+	poll := func() {
+		select {
+		case polling <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() { <-polling }()
+		if ctx.Err() != nil {
+			return
 		}
 
-		if configuration.MQTT.Enabled {
-			err = mqtt.Publish(logger, configuration.MQTT, *modemInformation)
-			if err != nil {
-				logger.Error("failed to write data to MQTT",
-					zap.String("op", "main"),
-					zap.Error(err),
-				)
-				return
-			}
+		logger.Debug("waking up",
+			zap.String("op", "main"),
+		)
+		scrapeCtx, cancelScrape := context.WithTimeout(ctx, scrapeTimeout)
+		modemInformation, err := session.Scrape(scrapeCtx)
+		cancelScrape()
+		if modemInformation == nil {
+			logger.Error("failed to scrape modem information",
+				zap.String("op", "main"),
+				zap.Error(err),
+			)
+			return
+		}
+		if err != nil {
+			// Publish the sections that were scraped.
+			logger.Warn("partially scraped modem information",
+				zap.String("op", "main"),
+				zap.Error(err),
+			)
 		}
-		if configuration.BoltDB.Enabled {
-			modemInformation, err = boltdb.PruneEventLogs(configuration.BoltDB, *modemInformation)
-			if err != nil {
-				logger.Error("failed to prune event logs from BoltDB",
-					zap.String("op", "main"),
-					zap.Error(err),
-				)
-				return
-			}
 
-			err = boltdb.UpdateEventLogs(logger, configuration.BoltDB, *modemInformation)
-			if err != nil {
-				logger.Error("failed to update event logs in BoltDB",
+		for _, result := range publishers.Publish(ctx, redactor.ModemInformation(*modemInformation)) {
+			if result.Err != nil {
+				scraperMetrics.ObservePublishError(result.Name)
+				logger.Error(fmt.Sprintf("failed to publish to %s", result.Name),
 					zap.String("op", "main"),
-					zap.Error(err),
+					zap.Error(result.Err),
 				)
-				return
 			}
 		}
 
 		logger.Debug("going back to sleep",
 			zap.String("op", "main"),
 		)
-	})
+	}
+
+	if configuration.Prometheus.Enabled {
+		publishers.Register(prom.NewPublisher(logger, promCollector))
+	}
+	if configuration.InfluxDB.Enabled {
+		influxPublisher, err := influxdb.NewPublisher(logger, configuration.InfluxDB)
+		if err != nil {
+			logger.Fatal("failed to set up InfluxDB",
+				zap.String("op", "main"),
+				zap.Error(err),
+			)
+		}
+		publishers.Register(influxPublisher)
+	}
+	var mqttPublisher *mqtt.Publisher
+	if configuration.MQTT.Enabled {
+		mqttPublisher, err = mqtt.NewPublisher(logger, configuration.MQTT)
+		if err != nil {
+			logger.Fatal("failed to set up MQTT",
+				zap.String("op", "main"),
+				zap.Error(err),
+			)
+		}
+		if configuration.MQTT.CommandTopic != "" {
+			mqttPublisher.HandleCommands(func(command string) error {
+				return runCommand(ctx, session, poll, scrapeTimeout, command)
+			})
+		}
+		publishers.Register(mqttPublisher)
+	}
+	if configuration.BoltDB.Enabled {
+		publishers.Register(boltdb.NewPublisher(logger, configuration.BoltDB))
+	}
+	logger.Info(fmt.Sprintf("publishing to: %s", strings.Join(publishers.Names(), ", ")),
+		zap.String("op", "main"),
+	)
+
+	if mqttPublisher != nil && configuration.MQTT.CommandTopic != "" {
+		// Connect now, rather than on the first poll, so that
+		// commands are received straight away.
+		err = mqttPublisher.Connect(ctx)
+		if err != nil {
+			logger.Warn("failed to connect to MQTT broker, will retry on the next poll",
+				zap.String("op", "main"),
+				zap.Error(err),
+			)
+		}
+	}
+
+	c := cron.New()
+	c.AddFunc(configuration.Polling.Schedule, poll)
 	go c.Start()
 
 	// Wait forever, but just for an OS interrupt/kill.
 	logger.Debug("started",
 		zap.String("op", "main"),
 	)
-	sig := make(chan os.Signal)
-	signal.Notify(sig, os.Interrupt, os.Kill)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
 	<-sig
+
+	logger.Info("shutting down",
+		zap.String("op", "main"),
+	)
+	c.Stop()
+	cancel()
+	if server != nil {
+		server.Close()
+	}
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	select {
+	case polling <- struct{}{}:
+	case <-shutdownCtx.Done():
+		logger.Warn("gave up waiting for poll to finish",
+			zap.String("op", "main"),
+		)
+	}
+
+	err = publishers.Close()
+	if err != nil {
+		logger.Error("failed to close publishers",
+			zap.String("op", "main"),
+			zap.Error(err),
+		)
+	}
+	err = session.Close(shutdownCtx)
+	if err != nil {
+		logger.Error("failed to log out of modem",
+			zap.String("op", "main"),
+			zap.Error(err),
+		)
+	}
+}
+
+// runCommand runs a command received over MQTT.
+func runCommand(ctx context.Context, session *scrape.Session, poll func(), timeout time.Duration, command string) error {
+	switch command {
+	case "scrape":
+		poll()
+		return nil
+	case "reboot":
+		rebootCtx, cancelReboot := context.WithTimeout(ctx, timeout)
+		defer cancelReboot()
+		return session.Reboot(rebootCtx)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
 }
 
 func parseConfiguration(configPath string) (*config.Configuration, error) {
@@ -166,3 +325,11 @@ func parseConfiguration(configPath string) (*config.Configuration, error) {
 
 	return &configuration, nil
 }
+
+func durationOrDefault(duration time.Duration, defaultDuration time.Duration) time.Duration {
+	if duration <= 0 {
+		return defaultDuration
+	}
+
+	return duration
+}
@@ -0,0 +1,196 @@
+package mqtt
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+type message struct {
+	Topic   string
+	Payload string
+	QoS     byte
+	Retain  bool
+}
+
+// testBroker is just enough of an MQTT broker to record what
+// clients connect and publish with, and to send them messages.
+type testBroker struct {
+	listener net.Listener
+
+	mu            sync.Mutex
+	connects      []*packets.ConnectPacket
+	messages      []message
+	subscriptions []string
+	conns         []net.Conn
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %s", err)
+	}
+
+	b := &testBroker{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			b.conns = append(b.conns, conn)
+			b.mu.Unlock()
+			go b.serve(conn)
+		}
+	}()
+
+	return b
+}
+
+func (b *testBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+
+		var reply packets.ControlPacket
+		b.mu.Lock()
+		switch p := packet.(type) {
+		case *packets.ConnectPacket:
+			b.connects = append(b.connects, p)
+			reply = packets.NewControlPacket(packets.Connack)
+		case *packets.PublishPacket:
+			b.messages = append(b.messages, message{Topic: p.TopicName, Payload: string(p.Payload), QoS: p.Qos, Retain: p.Retain})
+			switch p.Qos {
+			case 1:
+				puback := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				puback.MessageID = p.MessageID
+				reply = puback
+			case 2:
+				pubrec := packets.NewControlPacket(packets.Pubrec).(*packets.PubrecPacket)
+				pubrec.MessageID = p.MessageID
+				reply = pubrec
+			}
+		case *packets.PubrelPacket:
+			pubcomp := packets.NewControlPacket(packets.Pubcomp).(*packets.PubcompPacket)
+			pubcomp.MessageID = p.MessageID
+			reply = pubcomp
+		case *packets.SubscribePacket:
+			b.subscriptions = append(b.subscriptions, p.Topics...)
+			suback := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			suback.MessageID = p.MessageID
+			suback.ReturnCodes = p.Qoss
+			reply = suback
+		case *packets.PingreqPacket:
+			reply = packets.NewControlPacket(packets.Pingresp)
+		case *packets.DisconnectPacket:
+			b.mu.Unlock()
+			return
+		}
+		b.mu.Unlock()
+
+		if reply != nil {
+			if reply.Write(conn) != nil {
+				return
+			}
+		}
+	}
+}
+
+func (b *testBroker) url() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+// send publishes a message to every connected client.
+func (b *testBroker) send(topic string, payload string, retain bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	publish := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	publish.TopicName = topic
+	publish.Payload = []byte(payload)
+	publish.Retain = retain
+	for _, conn := range b.conns {
+		publish.Write(conn)
+	}
+}
+
+// dropConnections closes every client connection, as if the
+// broker went away.
+func (b *testBroker) dropConnections() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+	b.conns = nil
+}
+
+func (b *testBroker) close() {
+	b.listener.Close()
+	b.dropConnections()
+}
+
+// waitForMessages waits for the broker to have received at least
+// count messages on topic, and returns them.
+func (b *testBroker) waitForMessages(t *testing.T, topic string, count int) []message {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		matching := b.messagesOn(topic)
+		if len(matching) >= count || time.Now().After(deadline) {
+			if len(matching) < count {
+				t.Fatalf("got %d messages on %s, expected %d", len(matching), topic, count)
+			}
+			return matching
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (b *testBroker) messagesOn(topic string) []message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	matching := []message{}
+	for _, m := range b.messages {
+		if m.Topic == topic {
+			matching = append(matching, m)
+		}
+	}
+
+	return matching
+}
+
+// waitForSubscription waits for a client to have subscribed to topic.
+func (b *testBroker) waitForSubscription(t *testing.T, topic string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		b.mu.Lock()
+		for _, subscription := range b.subscriptions {
+			if subscription == topic {
+				b.mu.Unlock()
+				return
+			}
+		}
+		b.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no subscription to %s", topic)
+}
+
+func (b *testBroker) lastConnect() *packets.ConnectPacket {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.connects) == 0 {
+		return nil
+	}
+	return b.connects[len(b.connects)-1]
+}
@@ -0,0 +1,195 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/janse180/modem-scraper/scrape"
+)
+
+const defaultDiscoveryPrefix = "homeassistant"
+
+// discoveryConfig is a Home Assistant MQTT sensor, see
+// https://www.home-assistant.io/integrations/sensor.mqtt/
+type discoveryConfig struct {
+	Name              string          `json:"name"`
+	UniqueID          string          `json:"unique_id"`
+	StateTopic        string          `json:"state_topic"`
+	ValueTemplate     string          `json:"value_template"`
+	UnitOfMeasurement string          `json:"unit_of_measurement,omitempty"`
+	DeviceClass       string          `json:"device_class,omitempty"`
+	StateClass        string          `json:"state_class,omitempty"`
+	EntityCategory    string          `json:"entity_category,omitempty"`
+	Icon              string          `json:"icon,omitempty"`
+	AvailabilityTopic string          `json:"availability_topic"`
+	Device            discoveryDevice `json:"device"`
+}
+
+// discoveryDevice groups every sensor of the modem in Home Assistant.
+type discoveryDevice struct {
+	Identifiers []string   `json:"identifiers"`
+	Connections [][]string `json:"connections,omitempty"`
+	Name        string     `json:"name"`
+	HWVersion   string     `json:"hw_version,omitempty"`
+	SWVersion   string     `json:"sw_version,omitempty"`
+}
+
+var topicUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// buildDiscoveryMessages returns the Home Assistant discovery configs
+// for the sensors in modemInformation, which read their state from
+// the JSON published on stateTopic. Nothing is returned without a
+// serial number to identify the modem by.
+func buildDiscoveryMessages(prefix string, stateTopic string, availabilityTopic string, modemInformation scrape.ModemInformation) ([]outgoingMessage, error) {
+	softwareInformation := modemInformation.SoftwareInformation
+	if !modemInformation.Scraped(scrape.SectionSoftwareInformation) || softwareInformation.SerialNumber == "" {
+		return nil, nil
+	}
+
+	nodeID := topicUnsafe.ReplaceAllString(softwareInformation.SerialNumber, "_")
+	device := discoveryDevice{
+		Identifiers: []string{softwareInformation.SerialNumber},
+		Name:        "Modem " + softwareInformation.SerialNumber,
+		HWVersion:   softwareInformation.HardwareVersion,
+		SWVersion:   softwareInformation.SoftwareVersion,
+	}
+	if softwareInformation.MACAddress != "" {
+		device.Connections = [][]string{{"mac", strings.ToLower(softwareInformation.MACAddress)}}
+	}
+
+	sensors := map[string]discoveryConfig{
+		"uptime": {
+			Name:              "Uptime",
+			ValueTemplate:     "{{ value_json.SoftwareInformation.UptimeMins }}",
+			UnitOfMeasurement: "min",
+			DeviceClass:       "duration",
+			EntityCategory:    "diagnostic",
+		},
+		"software_version": {
+			Name:           "Firmware version",
+			ValueTemplate:  "{{ value_json.SoftwareInformation.SoftwareVersion }}",
+			EntityCategory: "diagnostic",
+			Icon:           "mdi:chip",
+		},
+	}
+
+	if modemInformation.Scraped(scrape.SectionConnectionStatus) {
+		steps := map[string]string{
+			"acquire_downstream_channel":    "AcquireDownstreamChannel",
+			"connectivity_state":            "ConnectivityState",
+			"boot_state":                    "BootState",
+			"configuration_file":            "ConfigurationFile",
+			"security":                      "Security",
+			"docsis_network_access_enabled": "DOCSISNetworkAccessEnabled",
+		}
+		for objectID, field := range steps {
+			sensors["startup_"+objectID] = discoveryConfig{
+				Name:           "Startup " + strings.Replace(objectID, "_", " ", -1),
+				ValueTemplate:  fmt.Sprintf("{{ value_json.ConnectionStatus.StartupProcedure.%s.Status }}", field),
+				EntityCategory: "diagnostic",
+				Icon:           "mdi:list-status",
+			}
+		}
+
+		for _, channel := range modemInformation.ConnectionStatus.DownstreamBondedChannels {
+			channelSensor := func(field string) string {
+				return channelTemplate("DownstreamBondedChannels", channel.ChannelID, field)
+			}
+			prefix := fmt.Sprintf("downstream_%d_", channel.ChannelID)
+			name := fmt.Sprintf("Downstream %d ", channel.ChannelID)
+			sensors[prefix+"power"] = discoveryConfig{
+				Name:              name + "power",
+				ValueTemplate:     channelSensor("PowerdBmV"),
+				UnitOfMeasurement: "dBmV",
+				StateClass:        "measurement",
+				Icon:              "mdi:signal",
+			}
+			sensors[prefix+"snr"] = discoveryConfig{
+				Name:              name + "SNR",
+				ValueTemplate:     channelSensor("SNRdB"),
+				UnitOfMeasurement: "dB",
+				StateClass:        "measurement",
+				Icon:              "mdi:signal-variant",
+			}
+			sensors[prefix+"corrected"] = discoveryConfig{
+				Name:          name + "corrected errors",
+				ValueTemplate: channelSensor("Corrected"),
+				StateClass:    "total_increasing",
+				Icon:          "mdi:alert-circle-check-outline",
+			}
+			sensors[prefix+"uncorrectables"] = discoveryConfig{
+				Name:          name + "uncorrectable errors",
+				ValueTemplate: channelSensor("Uncorrectables"),
+				StateClass:    "total_increasing",
+				Icon:          "mdi:alert-circle-outline",
+			}
+		}
+
+		for _, channel := range modemInformation.ConnectionStatus.UpstreamBondedChannels {
+			sensors[fmt.Sprintf("upstream_%d_power", channel.ChannelID)] = discoveryConfig{
+				Name:              fmt.Sprintf("Upstream %d power", channel.ChannelID),
+				ValueTemplate:     channelTemplate("UpstreamBondedChannels", channel.ChannelID, "PowerdBmV"),
+				UnitOfMeasurement: "dBmV",
+				StateClass:        "measurement",
+				Icon:              "mdi:signal",
+			}
+		}
+	}
+
+	messages := make([]outgoingMessage, 0, len(sensors))
+	for objectID, sensor := range sensors {
+		sensor.UniqueID = nodeID + "_" + objectID
+		sensor.StateTopic = stateTopic
+		sensor.AvailabilityTopic = availabilityTopic
+		sensor.Device = device
+
+		payload, err := json.Marshal(sensor)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, outgoingMessage{
+			Topic:   fmt.Sprintf("%s/sensor/%s/%s/config", prefix, nodeID, objectID),
+			Payload: string(payload),
+		})
+	}
+
+	return messages, nil
+}
+
+// channelTemplate picks field from the channel with channelID, as
+// channels can be listed in any order.
+func channelTemplate(channels string, channelID int, field string) string {
+	return fmt.Sprintf("{{ value_json.ConnectionStatus.%s | selectattr('ChannelID', 'equalto', %d) | map(attribute='%s') | first | default }}",
+		channels, channelID, field)
+}
+
+// publishDiscovery publishes the Home Assistant discovery configs
+// which changed since they were last published. They are retained,
+// so that Home Assistant finds them whenever it (re)starts.
+func (p *Publisher) publishDiscovery(ctx context.Context, modemInformation scrape.ModemInformation) error {
+	prefix := p.config.DiscoveryPrefix
+	if prefix == "" {
+		prefix = defaultDiscoveryPrefix
+	}
+
+	messages, err := buildDiscoveryMessages(prefix, p.config.Topic, p.availabilityTopic, modemInformation)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		if p.discovered[message.Topic] == message.Payload {
+			continue
+		}
+		err := waitToken(ctx, p.client.Publish(message.Topic, p.qos(), true, message.Payload))
+		if err != nil {
+			return fmt.Errorf("error publishing discovery config to %s: %s", message.Topic, err.Error())
+		}
+		p.discovered[message.Topic] = message.Payload
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/janse180/modem-scraper/config"
+	"github.com/janse180/modem-scraper/scrape"
+	"github.com/stretchr/testify/assert"
+)
+
+var discoveryModemInformation = scrape.ModemInformation{
+	ConnectionStatus: scrape.ConnectionStatus{
+		DownstreamBondedChannels: []scrape.DownstreamBondedChannel{
+			{ChannelID: 5, PowerdBmV: 4.1, SNRdB: 40.9},
+			{ChannelID: 6, PowerdBmV: 3.9, SNRdB: 40.4},
+		},
+		UpstreamBondedChannels: []scrape.UpstreamBondedChannel{
+			{Channel: 1, ChannelID: 2, PowerdBmV: 41.0},
+		},
+	},
+	SoftwareInformation: scrape.SoftwareInformation{
+		HardwareVersion: "6",
+		SoftwareVersion: "AB01.01.009.32_051619_183.0A.NSH",
+		MACAddress:      "TH:IS:IS:FA:KE:00",
+		SerialNumber:    "THISISFAKE12345",
+		UptimeMins:      4591,
+	},
+}
+
+func discoveryConfigs(t *testing.T, messages []outgoingMessage) map[string]discoveryConfig {
+	configs := map[string]discoveryConfig{}
+	for _, message := range messages {
+		var config discoveryConfig
+		if err := json.Unmarshal([]byte(message.Payload), &config); err != nil {
+			t.Fatalf("invalid discovery config on %s: %s", message.Topic, err)
+		}
+		configs[message.Topic] = config
+	}
+
+	return configs
+}
+
+func TestBuildDiscoveryMessages(t *testing.T) {
+	messages, err := buildDiscoveryMessages("homeassistant", "modem", "modem/availability", discoveryModemInformation)
+	assert.NoError(t, err)
+
+	// Uptime, firmware, 6 startup steps, 4 per downstream and 1 per upstream channel.
+	configs := discoveryConfigs(t, messages)
+	assert.Len(t, configs, 2+6+2*4+1)
+
+	snr := configs["homeassistant/sensor/THISISFAKE12345/downstream_6_snr/config"]
+	assert.Equal(t, "Downstream 6 SNR", snr.Name)
+	assert.Equal(t, "THISISFAKE12345_downstream_6_snr", snr.UniqueID)
+	assert.Equal(t, "modem", snr.StateTopic)
+	assert.Equal(t, "modem/availability", snr.AvailabilityTopic)
+	assert.Equal(t, "dB", snr.UnitOfMeasurement)
+	assert.Equal(t, "{{ value_json.ConnectionStatus.DownstreamBondedChannels | selectattr('ChannelID', 'equalto', 6) | map(attribute='SNRdB') | first | default }}", snr.ValueTemplate)
+	assert.Equal(t, discoveryDevice{
+		Identifiers: []string{"THISISFAKE12345"},
+		Connections: [][]string{{"mac", "th:is:is:fa:ke:00"}},
+		Name:        "Modem THISISFAKE12345",
+		HWVersion:   "6",
+		SWVersion:   "AB01.01.009.32_051619_183.0A.NSH",
+	}, snr.Device)
+
+	uncorrectables := configs["homeassistant/sensor/THISISFAKE12345/downstream_5_uncorrectables/config"]
+	assert.Equal(t, "total_increasing", uncorrectables.StateClass)
+
+	uptime := configs["homeassistant/sensor/THISISFAKE12345/uptime/config"]
+	assert.Equal(t, "{{ value_json.SoftwareInformation.UptimeMins }}", uptime.ValueTemplate)
+	assert.Equal(t, "duration", uptime.DeviceClass)
+
+	boot := configs["homeassistant/sensor/THISISFAKE12345/startup_boot_state/config"]
+	assert.Equal(t, "{{ value_json.ConnectionStatus.StartupProcedure.BootState.Status }}", boot.ValueTemplate)
+
+	assert.Contains(t, configs, "homeassistant/sensor/THISISFAKE12345/upstream_2_power/config")
+}
+
+func TestBuildDiscoveryMessagesNeedsSerialNumber(t *testing.T) {
+	modemInformation := discoveryModemInformation
+	modemInformation.FailedSections = []string{scrape.SectionSoftwareInformation}
+
+	messages, err := buildDiscoveryMessages("homeassistant", "modem", "modem/availability", modemInformation)
+	assert.NoError(t, err)
+	assert.Empty(t, messages)
+}
+
+func TestPublisherPublishesDiscoveryOnlyWhenChanged(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.close()
+	publisher := newTestPublisher(t, broker, config.MQTT{HomeAssistantDiscovery: true})
+	defer publisher.Close()
+
+	assert.NoError(t, publisher.Publish(context.Background(), discoveryModemInformation))
+	assert.NoError(t, publisher.Publish(context.Background(), discoveryModemInformation))
+	broker.waitForMessages(t, "modem", 2)
+
+	uptime := broker.messagesOn("homeassistant/sensor/THISISFAKE12345/uptime/config")
+	assert.Len(t, uptime, 1)
+	assert.True(t, uptime[0].Retain)
+
+	// A new channel is discovered on the next poll.
+	modemInformation := discoveryModemInformation
+	modemInformation.ConnectionStatus.UpstreamBondedChannels = append(modemInformation.ConnectionStatus.UpstreamBondedChannels,
+		scrape.UpstreamBondedChannel{Channel: 2, ChannelID: 3})
+	assert.NoError(t, publisher.Publish(context.Background(), modemInformation))
+	broker.waitForMessages(t, "homeassistant/sensor/THISISFAKE12345/upstream_3_power/config", 1)
+	assert.Len(t, broker.messagesOn("homeassistant/sensor/THISISFAKE12345/uptime/config"), 1)
+}
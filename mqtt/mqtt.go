@@ -1,59 +1,377 @@
 package mqtt
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	MQTT "github.com/eclipse/paho.mqtt.golang"
 	"github.com/janse180/modem-scraper/config"
+	"github.com/janse180/modem-scraper/logging"
 	"github.com/janse180/modem-scraper/scrape"
 	"go.uber.org/zap"
 )
 
-// Publish publishes the jsonified modemInformation to
-// the MQTT server configuration within the given
-// configuration.
-func Publish(logger *zap.Logger, config config.MQTT, modemInformation scrape.ModemInformation) error {
-	start := time.Now()
+const (
+	availabilityOnline  = "online"
+	availabilityOffline = "offline"
+)
+
+// outgoingMessage is a payload and the topic it goes to.
+type outgoingMessage struct {
+	Topic   string
+	Payload string
+}
+
+// CommandHandler runs a command received on the command topic,
+// e.g. "scrape" or "reboot".
+type CommandHandler func(command string) error
+
+// Publisher publishes to MQTT as a publish.Publisher, over one
+// connection which is kept open, and re-established if lost,
+// between polls.
+type Publisher struct {
+	logger            *zap.Logger
+	config            config.MQTT
+	client            MQTT.Client
+	broker            string
+	availabilityTopic string
+
+	mu        sync.Mutex
+	connected bool
+
+	// discovered maps each Home Assistant discovery topic to the
+	// config last published there.
+	discovered map[string]string
+
+	// commandHandler runs commands from the command topic.
+	commandHandler CommandHandler
+
+	// previousEvents is the event log last published to the
+	// events topic, to only publish new events. It is nil until
+	// the first event log is scraped.
+	previousEvents map[scrape.EventLog]int
+}
+
+// NewPublisher creates a Publisher for the given MQTT configuration.
+// The broker is connected to on the first Publish.
+func NewPublisher(logger *zap.Logger, config config.MQTT) (*Publisher, error) {
+	broker, err := brokerURL(config)
+	if err != nil {
+		return nil, err
+	}
+	if config.QoS < 0 || config.QoS > 2 {
+		return nil, fmt.Errorf("invalid MQTT QoS %d, expected 0, 1 or 2", config.QoS)
+	}
+	availabilityTopic := config.AvailabilityTopic
+	if availabilityTopic == "" {
+		availabilityTopic = config.Topic + "/availability"
+	}
 
-	broker := makeBroker(config.Hostname, config.Port)
+	p := &Publisher{
+		logger:            logger,
+		config:            config,
+		broker:            broker,
+		availabilityTopic: availabilityTopic,
+		discovered:        map[string]string{},
+	}
 
 	opts := MQTT.NewClientOptions()
 	opts.AddBroker(broker)
 	opts.SetClientID(config.ClientID)
 	opts.SetUsername(config.Username)
 	opts.SetPassword(config.Password)
+	opts.SetAutoReconnect(true)
+	opts.SetMaxReconnectInterval(time.Minute)
+	opts.SetWill(availabilityTopic, availabilityOffline, p.qos(), true)
+	opts.SetOnConnectHandler(p.onConnect)
+	opts.SetConnectionLostHandler(p.onConnectionLost)
 
-	client := MQTT.NewClient(opts)
-	defer client.Disconnect(250)
+	if isTLS(broker) {
+		tlsConfig, err := newTLSConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
 
-	logger.Debug(fmt.Sprintf("connecting to MQTT server %s", broker),
-		zap.String("op", "mqtt.Publish"),
-	)
-	if token := client.Connect(); token.Wait() && token.Error() != nil {
-		return token.Error()
+	p.client = MQTT.NewClient(opts)
+
+	return p, nil
+}
+
+// HandleCommands runs handler for every message on the configured
+// command topic, once connected. It must be called before Connect
+// or the first Publish.
+func (p *Publisher) HandleCommands(handler CommandHandler) {
+	p.commandHandler = handler
+}
+
+// Connect connects to the broker ahead of the first Publish, so
+// that commands are received straight away.
+func (p *Publisher) Connect(ctx context.Context) error {
+	return p.connect(ctx)
+}
+
+// Name returns "mqtt".
+func (p *Publisher) Name() string {
+	return "mqtt"
+}
+
+// Publish publishes the jsonified modemInformation to the
+// configured topic, connecting first if need be.
+func (p *Publisher) Publish(ctx context.Context, modemInformation scrape.ModemInformation) error {
+	start := time.Now()
+
+	err := p.connect(ctx)
+	if err != nil {
+		return err
 	}
 
-	logger.Debug(fmt.Sprintf("publishing to topic %s", config.Topic),
+	p.logger.Debug(fmt.Sprintf("publishing to topic %s", p.config.Topic),
 		zap.String("op", "mqtt.Publish"),
 	)
 
+	if p.config.HomeAssistantDiscovery {
+		err = p.publishDiscovery(ctx, modemInformation)
+		if err != nil {
+			return err
+		}
+	}
+
 	payload, err := modemInformation.ToJSON()
 	if err != nil {
 		return err
 	}
 
-	token := client.Publish(config.Topic, byte(0), false, payload)
-	token.Wait()
+	err = p.publish(ctx, p.config.Topic, payload)
+	if err != nil {
+		return err
+	}
+
+	if p.config.TopicTree {
+		err = p.publishTopicTree(ctx, modemInformation)
+		if err != nil {
+			return err
+		}
+	}
 
 	elapsed := time.Since(start)
-	logger.Debug(fmt.Sprintf("finished publishing to MQTT, took %s", elapsed),
+	p.logger.Debug(fmt.Sprintf("finished publishing to MQTT, took %s", elapsed),
+		zap.String("op", "mqtt.Publish"),
+	)
+
+	return nil
+}
+
+// Close marks modem-scraper offline and disconnects.
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.connected {
+		return nil
+	}
+	p.connected = false
+
+	// The Last Will is only sent on an unexpected disconnect.
+	token := p.client.Publish(p.availabilityTopic, p.qos(), true, availabilityOffline)
+	token.WaitTimeout(time.Second)
+	p.client.Disconnect(250)
+
+	return token.Error()
+}
+
+// connect makes the initial connection to the broker. After that,
+// the client reconnects by itself.
+func (p *Publisher) connect(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.connected {
+		return nil
+	}
+
+	p.logger.Debug(fmt.Sprintf("connecting to MQTT server %s", logging.URL(p.broker)),
 		zap.String("op", "mqtt.Publish"),
 	)
+	err := waitToken(ctx, p.client.Connect())
+	if err != nil {
+		return fmt.Errorf("error connecting to MQTT server %s: %s", logging.URL(p.broker), err.Error())
+	}
+	p.connected = true
+
+	return nil
+}
+
+func (p *Publisher) publish(ctx context.Context, topic string, payload string) error {
+	err := waitToken(ctx, p.client.Publish(topic, p.qos(), p.config.Retain, payload))
+	if err != nil {
+		return fmt.Errorf("error publishing to %s: %s", topic, err.Error())
+	}
 
 	return nil
 }
 
+func (p *Publisher) onConnect(client MQTT.Client) {
+	p.logger.Info(fmt.Sprintf("connected to MQTT server %s", logging.URL(p.broker)),
+		zap.String("op", "mqtt.onConnect"),
+	)
+
+	// Don't block the client's connection goroutine on the publish.
+	go func() {
+		token := client.Publish(p.availabilityTopic, p.qos(), true, availabilityOnline)
+		if token.WaitTimeout(10*time.Second) && token.Error() != nil {
+			p.logger.Error("failed to publish availability",
+				zap.String("op", "mqtt.onConnect"),
+				zap.Error(token.Error()),
+			)
+		}
+
+		if p.config.CommandTopic != "" && p.commandHandler != nil {
+			p.subscribeCommands(client)
+		}
+	}()
+}
+
+// subscribeCommands subscribes to the command topic. Subscriptions
+// are made on every connect, as the broker may have lost them.
+func (p *Publisher) subscribeCommands(client MQTT.Client) {
+	token := client.Subscribe(p.config.CommandTopic, p.qos(), p.onCommand)
+	if token.WaitTimeout(10*time.Second) && token.Error() != nil {
+		p.logger.Error(fmt.Sprintf("failed to subscribe to %s", p.config.CommandTopic),
+			zap.String("op", "mqtt.subscribeCommands"),
+			zap.Error(token.Error()),
+		)
+	}
+}
+
+func (p *Publisher) onCommand(client MQTT.Client, msg MQTT.Message) {
+	command := strings.TrimSpace(string(msg.Payload()))
+
+	// A retained command would run again on every reconnect.
+	if msg.Retained() {
+		p.logger.Warn(fmt.Sprintf("ignoring retained command %q", command),
+			zap.String("op", "mqtt.onCommand"),
+		)
+		return
+	}
+
+	p.logger.Info(fmt.Sprintf("received command %q", command),
+		zap.String("op", "mqtt.onCommand"),
+	)
+	// Commands can take a while, don't hold up the client.
+	go func() {
+		err := p.commandHandler(command)
+		if err != nil {
+			p.logger.Error(fmt.Sprintf("failed to run command %q", command),
+				zap.String("op", "mqtt.onCommand"),
+				zap.Error(err),
+			)
+		}
+	}()
+}
+
+func (p *Publisher) onConnectionLost(client MQTT.Client, err error) {
+	p.logger.Warn(fmt.Sprintf("lost connection to MQTT server %s, reconnecting", logging.URL(p.broker)),
+		zap.String("op", "mqtt.onConnectionLost"),
+		zap.Error(err),
+	)
+}
+
+func (p *Publisher) qos() byte {
+	return byte(p.config.QoS)
+}
+
+// waitToken waits for token to complete, or for ctx to be done.
+func waitToken(ctx context.Context, token MQTT.Token) error {
+	done := make(chan struct{})
+	go func() {
+		token.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// brokerURL returns the broker to connect to, translating the
+// mqtt:// and mqtts:// schemes to the tcp:// and ssl:// schemes
+// the client understands.
+func brokerURL(config config.MQTT) (string, error) {
+	if config.Broker == "" {
+		return makeBroker(config.Hostname, config.Port), nil
+	}
+
+	broker, err := url.Parse(config.Broker)
+	if err != nil {
+		return "", fmt.Errorf("invalid MQTT broker %q: %s", config.Broker, err.Error())
+	}
+	switch broker.Scheme {
+	case "mqtt":
+		broker.Scheme = "tcp"
+	case "mqtts":
+		broker.Scheme = "ssl"
+	case "tcp", "ssl", "tls", "tcps", "ws", "wss":
+	default:
+		return "", fmt.Errorf("unsupported MQTT broker scheme %q", broker.Scheme)
+	}
+
+	return broker.String(), nil
+}
+
 func makeBroker(hostname string, port string) string {
 	return fmt.Sprintf("tcp://%s:%s", hostname, port)
 }
+
+func isTLS(broker string) bool {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case "ssl", "tls", "tcps", "wss":
+		return true
+	}
+	return false
+}
+
+func newTLSConfig(config config.MQTT) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.SkipVerifySsl,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if config.CACert != "" {
+		pem, err := ioutil.ReadFile(config.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("error reading MQTT CA certificate: %s", err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in MQTT CA certificate %s", config.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.ClientCert != "" || config.ClientKey != "" {
+		certificate, err := tls.LoadX509KeyPair(config.ClientCert, config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading MQTT client certificate: %s", err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,156 @@
+package mqtt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/janse180/modem-scraper/config"
+	"github.com/janse180/modem-scraper/scrape"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func newTestPublisher(t *testing.T, broker *testBroker, conf config.MQTT) *Publisher {
+	conf.Broker = broker.url()
+	conf.Topic = "modem"
+	conf.ClientID = "modem-scraper-test"
+	publisher, err := NewPublisher(zap.NewNop(), conf)
+	if err != nil {
+		t.Fatalf("unable to create publisher: %s", err)
+	}
+
+	return publisher
+}
+
+func TestPublisherPublishesJSONWithQoSAndRetain(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.close()
+	publisher := newTestPublisher(t, broker, config.MQTT{QoS: 1, Retain: true})
+	defer publisher.Close()
+
+	modemInformation := scrape.ModemInformation{SoftwareInformation: scrape.SoftwareInformation{SerialNumber: "THISISFAKE"}}
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, publisher.Publish(context.Background(), modemInformation))
+	}
+
+	messages := broker.waitForMessages(t, "modem", 2)
+	assert.Contains(t, messages[0].Payload, `"SerialNumber":"THISISFAKE"`)
+	assert.Equal(t, byte(1), messages[0].QoS)
+	assert.True(t, messages[0].Retain)
+
+	// One connection is kept for every poll.
+	broker.mu.Lock()
+	assert.Len(t, broker.connects, 1)
+	broker.mu.Unlock()
+}
+
+func TestPublisherReportsAvailability(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.close()
+	publisher := newTestPublisher(t, broker, config.MQTT{})
+
+	assert.NoError(t, publisher.Publish(context.Background(), scrape.ModemInformation{}))
+
+	connect := broker.lastConnect()
+	assert.True(t, connect.WillFlag)
+	assert.True(t, connect.WillRetain)
+	assert.Equal(t, "modem/availability", connect.WillTopic)
+	assert.Equal(t, "offline", string(connect.WillMessage))
+
+	online := broker.waitForMessages(t, "modem/availability", 1)
+	assert.Equal(t, message{Topic: "modem/availability", Payload: "online", Retain: true}, online[0])
+
+	assert.NoError(t, publisher.Close())
+	offline := broker.waitForMessages(t, "modem/availability", 2)
+	assert.Equal(t, "offline", offline[1].Payload)
+}
+
+func TestPublisherReconnects(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.close()
+	publisher := newTestPublisher(t, broker, config.MQTT{QoS: 1})
+	defer publisher.Close()
+
+	assert.NoError(t, publisher.Publish(context.Background(), scrape.ModemInformation{}))
+	broker.dropConnections()
+
+	// The client reconnects by itself, announcing it is online again.
+	broker.waitForMessages(t, "modem/availability", 2)
+	assert.NoError(t, publisher.Publish(context.Background(), scrape.ModemInformation{}))
+	broker.waitForMessages(t, "modem", 2)
+}
+
+func TestPublisherRunsCommands(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.close()
+	publisher := newTestPublisher(t, broker, config.MQTT{CommandTopic: "modem/command"})
+	defer publisher.Close()
+
+	commands := make(chan string, 2)
+	publisher.HandleCommands(func(command string) error {
+		commands <- command
+		return nil
+	})
+	assert.NoError(t, publisher.Connect(context.Background()))
+	broker.waitForSubscription(t, "modem/command")
+
+	// Retained commands are ignored, as they'd run on every reconnect.
+	broker.send("modem/command", "reboot", true)
+	broker.send("modem/command", " scrape\n", false)
+
+	select {
+	case command := <-commands:
+		assert.Equal(t, "scrape", command)
+	case <-time.After(5 * time.Second):
+		t.Fatal("command was not handled")
+	}
+
+	// Commands are subscribed to again after reconnecting.
+	broker.mu.Lock()
+	broker.subscriptions = nil
+	broker.mu.Unlock()
+	broker.dropConnections()
+	broker.waitForSubscription(t, "modem/command")
+	assert.Empty(t, commands)
+}
+
+func TestPublisherSurfacesConnectionErrors(t *testing.T) {
+	broker := newTestBroker(t)
+	publisher := newTestPublisher(t, broker, config.MQTT{})
+	broker.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Error(t, publisher.Publish(ctx, scrape.ModemInformation{}))
+}
+
+func TestNewPublisherValidatesConfiguration(t *testing.T) {
+	_, err := NewPublisher(zap.NewNop(), config.MQTT{QoS: 3})
+	assert.Error(t, err)
+
+	_, err = NewPublisher(zap.NewNop(), config.MQTT{Broker: "http://localhost"})
+	assert.Error(t, err)
+
+	_, err = NewPublisher(zap.NewNop(), config.MQTT{Broker: "mqtts://localhost:8883", CACert: "does-not-exist.pem"})
+	assert.Error(t, err)
+}
+
+func TestBrokerURL(t *testing.T) {
+	tests := []struct {
+		config   config.MQTT
+		expected string
+	}{
+		{config.MQTT{Hostname: "localhost", Port: "1883"}, "tcp://localhost:1883"},
+		{config.MQTT{Broker: "mqtt://broker:1883"}, "tcp://broker:1883"},
+		{config.MQTT{Broker: "mqtts://broker:8883"}, "ssl://broker:8883"},
+		{config.MQTT{Broker: "ssl://broker:8883", Hostname: "ignored"}, "ssl://broker:8883"},
+	}
+
+	for _, tt := range tests {
+		actual, err := brokerURL(tt.config)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, actual)
+		assert.Equal(t, tt.expected[:3] == "ssl", isTLS(actual))
+	}
+}
@@ -0,0 +1,133 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/janse180/modem-scraper/scrape"
+)
+
+// buildTopicTree returns a message per value in modemInformation,
+// on topics under topic such as downstream/<ChannelID>/snr_db,
+// upstream/<ChannelID>/power_dbmv and software/uptime_mins.
+func buildTopicTree(topic string, modemInformation scrape.ModemInformation) []outgoingMessage {
+	messages := []outgoingMessage{}
+	add := func(payload string, path string, args ...interface{}) {
+		messages = append(messages, outgoingMessage{
+			Topic:   topic + "/" + fmt.Sprintf(path, args...),
+			Payload: payload,
+		})
+	}
+
+	if modemInformation.Scraped(scrape.SectionConnectionStatus) {
+		connectionStatus := modemInformation.ConnectionStatus
+
+		steps := []struct {
+			name   string
+			status scrape.Status
+		}{
+			{"acquire_downstream_channel", connectionStatus.StartupProcedure.AcquireDownstreamChannel},
+			{"connectivity_state", connectionStatus.StartupProcedure.ConnectivityState},
+			{"boot_state", connectionStatus.StartupProcedure.BootState},
+			{"configuration_file", connectionStatus.StartupProcedure.ConfigurationFile},
+			{"security", connectionStatus.StartupProcedure.Security},
+			{"docsis_network_access_enabled", connectionStatus.StartupProcedure.DOCSISNetworkAccessEnabled},
+		}
+		for _, step := range steps {
+			add(step.status.Status, "startup/%s/status", step.name)
+			add(step.status.Comment, "startup/%s/comment", step.name)
+		}
+
+		for _, channel := range connectionStatus.DownstreamBondedChannels {
+			add(channel.LockStatus, "downstream/%d/lock_status", channel.ChannelID)
+			add(channel.Modulation, "downstream/%d/modulation", channel.ChannelID)
+			add(strconv.Itoa(channel.FrequencyHz), "downstream/%d/frequency_hz", channel.ChannelID)
+			add(formatFloat(channel.PowerdBmV), "downstream/%d/power_dbmv", channel.ChannelID)
+			add(formatFloat(channel.SNRdB), "downstream/%d/snr_db", channel.ChannelID)
+			add(strconv.Itoa(channel.Corrected), "downstream/%d/corrected", channel.ChannelID)
+			add(strconv.Itoa(channel.Uncorrectables), "downstream/%d/uncorrectables", channel.ChannelID)
+		}
+
+		for _, channel := range connectionStatus.UpstreamBondedChannels {
+			add(strconv.Itoa(channel.Channel), "upstream/%d/channel", channel.ChannelID)
+			add(channel.LockStatus, "upstream/%d/lock_status", channel.ChannelID)
+			add(channel.USChannelType, "upstream/%d/channel_type", channel.ChannelID)
+			add(strconv.Itoa(channel.FrequencyHz), "upstream/%d/frequency_hz", channel.ChannelID)
+			add(strconv.Itoa(channel.WidthHz), "upstream/%d/width_hz", channel.ChannelID)
+			add(formatFloat(channel.PowerdBmV), "upstream/%d/power_dbmv", channel.ChannelID)
+		}
+	}
+
+	if modemInformation.Scraped(scrape.SectionSoftwareInformation) {
+		softwareInformation := modemInformation.SoftwareInformation
+		add(softwareInformation.StandardSpecificationCompliant, "software/standard_specification_compliant")
+		add(softwareInformation.HardwareVersion, "software/hardware_version")
+		add(softwareInformation.SoftwareVersion, "software/software_version")
+		add(softwareInformation.MACAddress, "software/mac_address")
+		add(softwareInformation.SerialNumber, "software/serial_number")
+		add(strconv.Itoa(softwareInformation.UptimeMins), "software/uptime_mins")
+		add(softwareInformation.UptimeString, "software/uptime")
+	}
+
+	return messages
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// newEvents returns the entries of eventLog which were not in
+// previousEvents, along with the counts of eventLog's entries.
+func newEvents(previousEvents map[scrape.EventLog]int, eventLog []scrape.EventLog) ([]scrape.EventLog, map[scrape.EventLog]int) {
+	currentEvents := map[scrape.EventLog]int{}
+	events := []scrape.EventLog{}
+	for _, event := range eventLog {
+		currentEvents[event]++
+		if currentEvents[event] > previousEvents[event] {
+			events = append(events, event)
+		}
+	}
+
+	return events, currentEvents
+}
+
+// publishTopicTree publishes every value on a topic of its own, and
+// each new event log entry as JSON on the events topic. Events are
+// never retained, so that they aren't seen twice, and those already
+// in the event log at startup aren't published.
+func (p *Publisher) publishTopicTree(ctx context.Context, modemInformation scrape.ModemInformation) error {
+	for _, message := range buildTopicTree(p.config.Topic, modemInformation) {
+		err := p.publish(ctx, message.Topic, message.Payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !modemInformation.Scraped(scrape.SectionEventLog) {
+		return nil
+	}
+
+	eventsTopic := p.config.Topic + "/events"
+	events, currentEvents := newEvents(p.previousEvents, modemInformation.EventLog)
+	// The modem keeps its event log when modem-scraper restarts, so
+	// the first event log seen is only remembered, not published.
+	if p.previousEvents == nil {
+		events = nil
+	}
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		err = waitToken(ctx, p.client.Publish(eventsTopic, p.qos(), false, string(payload)))
+		if err != nil {
+			return fmt.Errorf("error publishing to %s: %s", eventsTopic, err.Error())
+		}
+	}
+	// Only forget the old event log once the new events are out.
+	p.previousEvents = currentEvents
+
+	return nil
+}
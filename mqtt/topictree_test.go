@@ -0,0 +1,100 @@
+package mqtt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/janse180/modem-scraper/config"
+	"github.com/janse180/modem-scraper/scrape"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildTopicTree(t *testing.T) {
+	modemInformation := scrape.ModemInformation{
+		ConnectionStatus: scrape.ConnectionStatus{
+			StartupProcedure: scrape.StartupProcedure{
+				BootState: scrape.Status{Status: "OK", Comment: "Operational"},
+			},
+			DownstreamBondedChannels: []scrape.DownstreamBondedChannel{
+				{ChannelID: 5, LockStatus: "Locked", Modulation: "QAM256", FrequencyHz: 507000000, PowerdBmV: 4.1, SNRdB: 40.9, Corrected: 12, Uncorrectables: 3},
+			},
+			UpstreamBondedChannels: []scrape.UpstreamBondedChannel{
+				{Channel: 1, ChannelID: 2, LockStatus: "Locked", USChannelType: "SC-QAM", FrequencyHz: 36500000, WidthHz: 6400000, PowerdBmV: 41},
+			},
+		},
+		SoftwareInformation: scrape.SoftwareInformation{SerialNumber: "THISISFAKE12345", UptimeMins: 4591},
+	}
+
+	values := map[string]string{}
+	for _, message := range buildTopicTree("modem", modemInformation) {
+		values[message.Topic] = message.Payload
+	}
+
+	assert.Equal(t, "40.9", values["modem/downstream/5/snr_db"])
+	assert.Equal(t, "4.1", values["modem/downstream/5/power_dbmv"])
+	assert.Equal(t, "3", values["modem/downstream/5/uncorrectables"])
+	assert.Equal(t, "507000000", values["modem/downstream/5/frequency_hz"])
+	assert.Equal(t, "41", values["modem/upstream/2/power_dbmv"])
+	assert.Equal(t, "SC-QAM", values["modem/upstream/2/channel_type"])
+	assert.Equal(t, "4591", values["modem/software/uptime_mins"])
+	assert.Equal(t, "THISISFAKE12345", values["modem/software/serial_number"])
+	assert.Equal(t, "OK", values["modem/startup/boot_state/status"])
+	assert.Equal(t, "Operational", values["modem/startup/boot_state/comment"])
+
+	// Failed sections are left out.
+	modemInformation.FailedSections = []string{scrape.SectionConnectionStatus}
+	for _, message := range buildTopicTree("modem", modemInformation) {
+		assert.NotContains(t, message.Topic, "stream/")
+	}
+}
+
+func TestPublisherPublishesOnlyNewEvents(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.close()
+	publisher := newTestPublisher(t, broker, config.MQTT{TopicTree: true, Retain: true})
+	defer publisher.Close()
+
+	eastern := time.FixedZone("EDT", -4*60*60)
+	t3 := scrape.EventLog{DateTime: time.Date(2019, 10, 10, 21, 43, 0, 0, eastern), RawDateTime: "10/10/2019 21:43", EventID: 82000200, EventLevel: 3, Description: "T3 time-out"}
+	t4 := scrape.EventLog{DateTime: time.Date(2019, 10, 10, 21, 44, 0, 0, eastern), RawDateTime: "10/10/2019 21:44", EventID: 82000300, EventLevel: 3, Description: "T4 time-out"}
+
+	assert.NoError(t, publisher.Publish(context.Background(), scrape.ModemInformation{EventLog: []scrape.EventLog{}}))
+	assert.NoError(t, publisher.Publish(context.Background(), scrape.ModemInformation{EventLog: []scrape.EventLog{t3}}))
+	assert.NoError(t, publisher.Publish(context.Background(), scrape.ModemInformation{EventLog: []scrape.EventLog{t3, t4}}))
+	// A failed event log doesn't make old events new again.
+	assert.NoError(t, publisher.Publish(context.Background(), scrape.ModemInformation{FailedSections: []string{scrape.SectionEventLog}}))
+	assert.NoError(t, publisher.Publish(context.Background(), scrape.ModemInformation{EventLog: []scrape.EventLog{t3, t4}}))
+	uptime := broker.waitForMessages(t, "modem/software/uptime_mins", 5)
+	assert.True(t, uptime[0].Retain)
+
+	events := broker.messagesOn("modem/events")
+	assert.Len(t, events, 2)
+	assert.Equal(t, `{"DateTime":"2019-10-10T21:43:00-04:00","RawDateTime":"10/10/2019 21:43","EventID":82000200,"EventLevel":3,"Description":"T3 time-out"}`, events[0].Payload)
+	assert.Contains(t, events[1].Payload, "T4 time-out")
+	assert.False(t, events[0].Retain)
+}
+
+func TestPublisherDoesNotRepublishEventsAfterRestart(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.close()
+
+	t3 := scrape.EventLog{RawDateTime: "Time Not Established", EventID: 82000200, EventLevel: 3, Description: "T3 time-out"}
+	t4 := scrape.EventLog{RawDateTime: "Time Not Established", EventID: 82000300, EventLevel: 3, Description: "T4 time-out"}
+	t5 := scrape.EventLog{RawDateTime: "Time Not Established", EventID: 82000400, EventLevel: 3, Description: "T5 time-out"}
+
+	for _, eventLog := range [][]scrape.EventLog{{t3}, {t3, t4}} {
+		// Each publisher starts afresh, as after a restart.
+		publisher := newTestPublisher(t, broker, config.MQTT{TopicTree: true})
+		// A failed event log doesn't count as the first one seen.
+		assert.NoError(t, publisher.Publish(context.Background(), scrape.ModemInformation{FailedSections: []string{scrape.SectionEventLog}}))
+		assert.NoError(t, publisher.Publish(context.Background(), scrape.ModemInformation{EventLog: eventLog}))
+		assert.NoError(t, publisher.Publish(context.Background(), scrape.ModemInformation{EventLog: append(eventLog, t5)}))
+		publisher.Close()
+	}
+	events := broker.waitForMessages(t, "modem/events", 2)
+	assert.Len(t, events, 2)
+	for _, event := range events {
+		assert.Contains(t, event.Payload, "T5 time-out")
+	}
+}
@@ -1,14 +1,146 @@
 package prom
 
 import (
+	"context"
 	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/janse180/modem-scraper/scrape"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 )
 
-func Publish(logger *zap.Logger, modemInformation scrape.ModemInformation) error {
+var (
+	downstreamBondedChannelLabels = []string{
+		"ChannelID",
+		"LockStatus",
+		"Modulation",
+		"FrequencyHz",
+	}
+	upstreamBondedChannelLabels = []string{
+		"Channel",
+		"ChannelID",
+		"LockStatus",
+		"USChannelType",
+		"FrequencyHz",
+		"WidthHz",
+	}
+
+	downstreamBondedChannelPowerDesc = prometheus.NewDesc(
+		"downstream_bonded_channel_powerdbmv",
+		"The downstream bonded channel power",
+		downstreamBondedChannelLabels, nil,
+	)
+	downstreamBondedChannelSNRDesc = prometheus.NewDesc(
+		"downstream_bonded_channel_snrdb",
+		"The downstream bonded channel snr",
+		downstreamBondedChannelLabels, nil,
+	)
+	downstreamBondedChannelCorrectedDesc = prometheus.NewDesc(
+		"downstream_bonded_channel_error_corrected",
+		"The downstream bonded channel corrected errors",
+		downstreamBondedChannelLabels, nil,
+	)
+	downstreamBondedChannelUncorrectedDesc = prometheus.NewDesc(
+		"downstream_bonded_channel_error_uncorrected",
+		"The downstream bonded channel uncorrected errors",
+		downstreamBondedChannelLabels, nil,
+	)
+	upstreamBondedChannelPowerDesc = prometheus.NewDesc(
+		"upstream_bonded_channel_powerdbmv",
+		"The upstream bonded channel power",
+		upstreamBondedChannelLabels, nil,
+	)
+	infoDesc = prometheus.NewDesc(
+		"modem_info",
+		"Modem firmware, hardware and serial number, always 1",
+		[]string{"firmware_version", "hardware_version", "serial_number"}, nil,
+	)
+	uptimeDesc = prometheus.NewDesc(
+		"modem_uptime_seconds",
+		"The modem uptime, with minute precision",
+		nil, nil,
+	)
+	startupProcedureDesc = prometheus.NewDesc(
+		"modem_startup_procedure_status",
+		"Whether the startup procedure step succeeded (1) or not (0)",
+		[]string{"step"}, nil,
+	)
+	eventLogDesc = prometheus.NewDesc(
+		"modem_event_log_events_total",
+		"The number of event log entries logged since modem-scraper started",
+		[]string{"event_level", "event_id", "severity", "code", "category"}, nil,
+	)
+)
+
+// eventKey identifies an event log counter.
+type eventKey struct {
+	EventLevel int
+	EventID    int
+}
+
+// Collector is a prometheus.Collector which exports the last
+// successfully scraped ModemInformation. Metrics are built on
+// every collection, so channels which disappear from the modem
+// also disappear from /metrics.
+type Collector struct {
+	mu               sync.RWMutex
+	modemInformation *scrape.ModemInformation
+	scrapedAt        time.Time
+	// The event log only holds recent entries, so entries are counted
+	// when they are first seen, i.e. when they were absent (or fewer)
+	// in the previous log. It is nil until the first event log, which
+	// is only a baseline, as its entries were logged before startup.
+	previousEvents map[scrape.EventLog]int
+	eventCounts    map[eventKey]float64
+
+	// Only set for on-demand collectors.
+	logger      *zap.Logger
+	scraper     Scraper
+	minInterval time.Duration
+	flightMu    sync.Mutex
+	flight      *scrapeFlight
+}
+
+// Scraper fetches fresh ModemInformation for an on-demand Collector.
+// Like Session.Scrape, it may return partial ModemInformation
+// alongside an error.
+type Scraper func(ctx context.Context) (*scrape.ModemInformation, error)
+
+// scrapeFlight is a scrape in progress, shared by every collection
+// that arrives while it runs.
+type scrapeFlight struct {
+	wg               sync.WaitGroup
+	modemInformation *scrape.ModemInformation
+	err              error
+}
+
+// NewCollector creates a Collector with no data; it exports
+// nothing until the first call to Publish.
+func NewCollector() *Collector {
+	return &Collector{
+		eventCounts: map[eventKey]float64{},
+	}
+}
+
+// NewOnDemandCollector creates a Collector which scrapes the modem
+// when it is collected, like a classic exporter. Results are reused
+// for minInterval, and concurrent collections share a single scrape
+// so that several Prometheus servers don't hammer the modem.
+func NewOnDemandCollector(logger *zap.Logger, scraper Scraper, minInterval time.Duration) *Collector {
+	return &Collector{
+		eventCounts: map[eventKey]float64{},
+		logger:      logger,
+		scraper:     scraper,
+		minInterval: minInterval,
+	}
+}
+
+// Publish replaces the ModemInformation exported by the collector.
+// It never blocks, ctx is taken to match the other publishers.
+func (c *Collector) Publish(ctx context.Context, logger *zap.Logger, modemInformation scrape.ModemInformation) error {
 
 	start := time.Now()
 
@@ -16,7 +148,14 @@ func Publish(logger *zap.Logger, modemInformation scrape.ModemInformation) error
 		zap.String("op", "prometheus.Publish"),
 	)
 
-	modemInformation.ConnectionStatus.UpdateGauge()
+	c.mu.Lock()
+	c.modemInformation = &modemInformation
+	c.scrapedAt = time.Now()
+	// A failed event log would look like every event had cleared.
+	if modemInformation.Scraped(scrape.SectionEventLog) {
+		c.countEvents(modemInformation.EventLog)
+	}
+	c.mu.Unlock()
 
 	elapsed := time.Since(start)
 	logger.Debug(fmt.Sprintf("finished exporting prometheus metrics, took %s", elapsed),
@@ -25,3 +164,242 @@ func Publish(logger *zap.Logger, modemInformation scrape.ModemInformation) error
 
 	return nil
 }
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- downstreamBondedChannelPowerDesc
+	ch <- downstreamBondedChannelSNRDesc
+	ch <- downstreamBondedChannelCorrectedDesc
+	ch <- downstreamBondedChannelUncorrectedDesc
+	ch <- upstreamBondedChannelPowerDesc
+	ch <- infoDesc
+	ch <- uptimeDesc
+	ch <- startupProcedureDesc
+	ch <- eventLogDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	var modemInformation *scrape.ModemInformation
+	if c.scraper != nil {
+		modemInformation = c.scrapeOnDemand()
+	} else {
+		c.mu.RLock()
+		modemInformation = c.modemInformation
+		c.mu.RUnlock()
+	}
+
+	c.collectEventCounts(ch)
+
+	if modemInformation == nil {
+		return
+	}
+
+	if modemInformation.Scraped(scrape.SectionSoftwareInformation) {
+		collectSoftwareInformation(ch, modemInformation.SoftwareInformation)
+	}
+	if !modemInformation.Scraped(scrape.SectionConnectionStatus) {
+		return
+	}
+
+	collectStartupProcedure(ch, modemInformation.ConnectionStatus.StartupProcedure)
+	for _, channel := range modemInformation.ConnectionStatus.DownstreamBondedChannels {
+		collectDownstreamBondedChannel(ch, channel)
+	}
+	for _, channel := range modemInformation.ConnectionStatus.UpstreamBondedChannels {
+		collectUpstreamBondedChannel(ch, channel)
+	}
+}
+
+// countEvents adds the entries of eventLog which were not in the
+// previous log to the event counters. c.mu must be held.
+func (c *Collector) countEvents(eventLog []scrape.EventLog) {
+	currentEvents := map[scrape.EventLog]int{}
+	for _, event := range eventLog {
+		currentEvents[event]++
+	}
+
+	for event, count := range currentEvents {
+		key := eventKey{EventLevel: event.EventLevel, EventID: event.EventID}
+		if c.previousEvents == nil {
+			// The baseline's counters start at zero, so that increase()
+			// sees the first new entry.
+			c.eventCounts[key] += 0
+			continue
+		}
+		if newEvents := count - c.previousEvents[event]; newEvents > 0 {
+			c.eventCounts[key] += float64(newEvents)
+		}
+	}
+	c.previousEvents = currentEvents
+}
+
+func (c *Collector) collectEventCounts(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for key, count := range c.eventCounts {
+		eventCode, _ := scrape.LookupEventCode(key.EventID)
+		ch <- prometheus.MustNewConstMetric(eventLogDesc, prometheus.CounterValue, count,
+			strconv.Itoa(key.EventLevel),
+			strconv.Itoa(key.EventID),
+			scrape.SeverityName(key.EventLevel),
+			eventCode.Code,
+			eventCode.Category,
+		)
+	}
+}
+
+func collectSoftwareInformation(ch chan<- prometheus.Metric, s scrape.SoftwareInformation) {
+	ch <- prometheus.MustNewConstMetric(infoDesc, prometheus.GaugeValue, 1,
+		s.SoftwareVersion,
+		s.HardwareVersion,
+		s.SerialNumber,
+	)
+	ch <- prometheus.MustNewConstMetric(uptimeDesc, prometheus.GaugeValue, float64(s.UptimeMins*60))
+}
+
+func collectStartupProcedure(ch chan<- prometheus.Metric, s scrape.StartupProcedure) {
+	steps := map[string]scrape.Status{
+		"acquire_downstream_channel":    s.AcquireDownstreamChannel,
+		"connectivity_state":            s.ConnectivityState,
+		"boot_state":                    s.BootState,
+		"configuration_file":            s.ConfigurationFile,
+		"security":                      s.Security,
+		"docsis_network_access_enabled": s.DOCSISNetworkAccessEnabled,
+	}
+
+	for step, status := range steps {
+		ch <- prometheus.MustNewConstMetric(startupProcedureDesc, prometheus.GaugeValue, statusValue(status), step)
+	}
+}
+
+// statusValue is 1 when a startup procedure step succeeded.
+func statusValue(status scrape.Status) float64 {
+	if status.OK() {
+		return 1
+	}
+	return 0
+}
+
+func collectDownstreamBondedChannel(ch chan<- prometheus.Metric, d scrape.DownstreamBondedChannel) {
+	labels := []string{
+		strconv.Itoa(d.ChannelID),
+		d.LockStatus,
+		d.Modulation,
+		strconv.Itoa(d.FrequencyHz),
+	}
+
+	ch <- prometheus.MustNewConstMetric(downstreamBondedChannelPowerDesc, prometheus.GaugeValue, d.PowerdBmV, labels...)
+	ch <- prometheus.MustNewConstMetric(downstreamBondedChannelSNRDesc, prometheus.GaugeValue, d.SNRdB, labels...)
+	ch <- prometheus.MustNewConstMetric(downstreamBondedChannelCorrectedDesc, prometheus.GaugeValue, float64(d.Corrected), labels...)
+	ch <- prometheus.MustNewConstMetric(downstreamBondedChannelUncorrectedDesc, prometheus.GaugeValue, float64(d.Uncorrectables), labels...)
+}
+
+func collectUpstreamBondedChannel(ch chan<- prometheus.Metric, u scrape.UpstreamBondedChannel) {
+	labels := []string{
+		strconv.Itoa(u.Channel),
+		strconv.Itoa(u.ChannelID),
+		u.LockStatus,
+		u.USChannelType,
+		strconv.Itoa(u.FrequencyHz),
+		strconv.Itoa(u.WidthHz),
+	}
+
+	ch <- prometheus.MustNewConstMetric(upstreamBondedChannelPowerDesc, prometheus.GaugeValue, u.PowerdBmV, labels...)
+}
+
+// scrapeOnDemand returns the cached ModemInformation if it is newer
+// than minInterval, and otherwise scrapes the modem, joining a scrape
+// already in progress if there is one. Nothing is returned when the
+// scrape fails, so the modem's metrics are absent rather than stale.
+func (c *Collector) scrapeOnDemand() *scrape.ModemInformation {
+	c.mu.RLock()
+	if c.modemInformation != nil && time.Since(c.scrapedAt) < c.minInterval {
+		defer c.mu.RUnlock()
+		return c.modemInformation
+	}
+	c.mu.RUnlock()
+
+	c.flightMu.Lock()
+	flight := c.flight
+	if flight == nil {
+		flight = &scrapeFlight{}
+		flight.wg.Add(1)
+		c.flight = flight
+		c.flightMu.Unlock()
+
+		c.runFlight(flight)
+	} else {
+		c.flightMu.Unlock()
+		flight.wg.Wait()
+	}
+
+	if flight.modemInformation == nil {
+		c.logger.Error("failed to scrape modem information",
+			zap.String("op", "prometheus.Collect"),
+			zap.Error(flight.err),
+		)
+		return nil
+	}
+	if flight.err != nil {
+		c.logger.Warn("partially scraped modem information",
+			zap.String("op", "prometheus.Collect"),
+			zap.Error(flight.err),
+		)
+	}
+
+	return flight.modemInformation
+}
+
+// runFlight scrapes for flight, then releases the requests waiting
+// on it. Collect runs in a goroutine of the registry's own, where a
+// panic would stop the process, so a panic in the scrape fails it
+// instead.
+func (c *Collector) runFlight(flight *scrapeFlight) {
+	defer func() {
+		if r := recover(); r != nil {
+			flight.modemInformation = nil
+			flight.err = fmt.Errorf("panic while scraping: %v", r)
+		}
+
+		c.flightMu.Lock()
+		c.flight = nil
+		c.flightMu.Unlock()
+		flight.wg.Done()
+	}()
+
+	flight.modemInformation, flight.err = c.scraper(context.Background())
+	if flight.modemInformation != nil {
+		c.Publish(context.Background(), c.logger, *flight.modemInformation)
+	}
+}
+
+// Publisher publishes to a Collector as a publish.Publisher.
+type Publisher struct {
+	logger    *zap.Logger
+	collector *Collector
+}
+
+// NewPublisher creates a Publisher for collector.
+func NewPublisher(logger *zap.Logger, collector *Collector) *Publisher {
+	return &Publisher{
+		logger:    logger,
+		collector: collector,
+	}
+}
+
+// Name returns "prometheus".
+func (p *Publisher) Name() string {
+	return "prometheus"
+}
+
+// Publish replaces the ModemInformation exported by the collector.
+func (p *Publisher) Publish(ctx context.Context, modemInformation scrape.ModemInformation) error {
+	return p.collector.Publish(ctx, p.logger, modemInformation)
+}
+
+// Close is a no-op, the collector stays registered.
+func (p *Publisher) Close() error {
+	return nil
+}
@@ -0,0 +1,283 @@
+package prom
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/janse180/modem-scraper/scrape"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCollectorExportsNothingBeforePublish(t *testing.T) {
+	collector := NewCollector()
+
+	err := testutil.CollectAndCompare(collector, strings.NewReader(""))
+	assert.NoError(t, err)
+}
+
+func TestCollectorDropsChannelsThatRelock(t *testing.T) {
+	collector := NewCollector()
+
+	modemInformation := scrape.ModemInformation{
+		ConnectionStatus: scrape.ConnectionStatus{
+			DownstreamBondedChannels: []scrape.DownstreamBondedChannel{
+				{ChannelID: 1, LockStatus: "Locked", Modulation: "QAM256", FrequencyHz: 507000000, PowerdBmV: 5.3},
+			},
+		},
+	}
+	assert.NoError(t, collector.Publish(context.Background(), zap.NewNop(), modemInformation))
+
+	// The channel re-locks on a new frequency; the old series must go.
+	modemInformation.ConnectionStatus.DownstreamBondedChannels[0].FrequencyHz = 513000000
+	modemInformation.ConnectionStatus.DownstreamBondedChannels[0].PowerdBmV = 4.1
+	assert.NoError(t, collector.Publish(context.Background(), zap.NewNop(), modemInformation))
+
+	expected := `
+# HELP downstream_bonded_channel_powerdbmv The downstream bonded channel power
+# TYPE downstream_bonded_channel_powerdbmv gauge
+downstream_bonded_channel_powerdbmv{ChannelID="1",FrequencyHz="513000000",LockStatus="Locked",Modulation="QAM256"} 4.1
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "downstream_bonded_channel_powerdbmv")
+	assert.NoError(t, err)
+}
+
+func TestCollectorExportsUpstreamBondedChannels(t *testing.T) {
+	collector := NewCollector()
+
+	modemInformation := scrape.ModemInformation{
+		ConnectionStatus: scrape.ConnectionStatus{
+			UpstreamBondedChannels: []scrape.UpstreamBondedChannel{
+				{Channel: 1, ChannelID: 2, LockStatus: "Locked", USChannelType: "SC-QAM", FrequencyHz: 16400000, WidthHz: 6400000, PowerdBmV: 42.5},
+			},
+		},
+	}
+	assert.NoError(t, collector.Publish(context.Background(), zap.NewNop(), modemInformation))
+
+	expected := `
+# HELP upstream_bonded_channel_powerdbmv The upstream bonded channel power
+# TYPE upstream_bonded_channel_powerdbmv gauge
+upstream_bonded_channel_powerdbmv{Channel="1",ChannelID="2",FrequencyHz="16400000",LockStatus="Locked",USChannelType="SC-QAM",WidthHz="6400000"} 42.5
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "upstream_bonded_channel_powerdbmv")
+	assert.NoError(t, err)
+}
+
+func TestOnDemandCollectorReusesScrapeWithinMinInterval(t *testing.T) {
+	var scrapes int32
+	collector := NewOnDemandCollector(zap.NewNop(), func(ctx context.Context) (*scrape.ModemInformation, error) {
+		atomic.AddInt32(&scrapes, 1)
+		return &scrape.ModemInformation{}, nil
+	}, time.Hour)
+
+	testutil.CollectAndCompare(collector, strings.NewReader(""))
+	testutil.CollectAndCompare(collector, strings.NewReader(""))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&scrapes))
+}
+
+func TestOnDemandCollectorSharesConcurrentScrapes(t *testing.T) {
+	var scrapes int32
+	release := make(chan struct{})
+	collector := NewOnDemandCollector(zap.NewNop(), func(ctx context.Context) (*scrape.ModemInformation, error) {
+		atomic.AddInt32(&scrapes, 1)
+		<-release
+		return &scrape.ModemInformation{}, nil
+	}, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			testutil.CollectAndCompare(collector, strings.NewReader(""))
+		}()
+	}
+	// Give every collection a chance to join the scrape in flight.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&scrapes))
+}
+
+func TestOnDemandCollectorExportsNothingWhenScrapeFails(t *testing.T) {
+	collector := NewOnDemandCollector(zap.NewNop(), func(ctx context.Context) (*scrape.ModemInformation, error) {
+		return nil, errors.New("modem unreachable")
+	}, time.Hour)
+
+	err := testutil.CollectAndCompare(collector, strings.NewReader(""))
+	assert.NoError(t, err)
+}
+
+func TestOnDemandCollectorRecoversFromScrapePanics(t *testing.T) {
+	var scrapes int32
+	core, logs := observer.New(zapcore.ErrorLevel)
+	collector := NewOnDemandCollector(zap.New(core), func(ctx context.Context) (*scrape.ModemInformation, error) {
+		if atomic.AddInt32(&scrapes, 1) == 1 {
+			panic("unexpected page")
+		}
+		return &scrape.ModemInformation{}, nil
+	}, 0)
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(collector)
+
+	// The registry collects in goroutines of its own, so a panic
+	// which got that far would stop the test binary.
+	_, err := registry.Gather()
+	assert.NoError(t, err)
+	failures := logs.FilterMessage("failed to scrape modem information").All()
+	if assert.Len(t, failures, 1) {
+		assert.Contains(t, failures[0].ContextMap()["error"], "unexpected page")
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := registry.Gather()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&scrapes))
+	case <-time.After(time.Second):
+		t.Fatal("scrape after a panic blocked on the panicked scrape")
+	}
+}
+
+func TestCollectorExportsSoftwareInformationAndStartupProcedure(t *testing.T) {
+	collector := NewCollector()
+
+	modemInformation := scrape.ModemInformation{
+		ConnectionStatus: scrape.ConnectionStatus{
+			StartupProcedure: scrape.StartupProcedure{
+				AcquireDownstreamChannel:   scrape.Status{Status: "519000000 Hz", Comment: "Locked"},
+				ConnectivityState:          scrape.Status{Status: "OK", Comment: "Operational"},
+				BootState:                  scrape.Status{Status: "OK", Comment: "Operational"},
+				ConfigurationFile:          scrape.Status{Status: "OK"},
+				Security:                   scrape.Status{Status: "Enabled", Comment: "BPI+"},
+				DOCSISNetworkAccessEnabled: scrape.Status{Status: "Denied"},
+			},
+		},
+		SoftwareInformation: scrape.SoftwareInformation{
+			HardwareVersion: "4",
+			SoftwareVersion: "SB8200.0200.174F.311915.NSH.RT.NA",
+			SerialNumber:    "THISISFAKE12345",
+			UptimeMins:      2292,
+		},
+	}
+	assert.NoError(t, collector.Publish(context.Background(), zap.NewNop(), modemInformation))
+
+	expected := `
+# HELP modem_info Modem firmware, hardware and serial number, always 1
+# TYPE modem_info gauge
+modem_info{firmware_version="SB8200.0200.174F.311915.NSH.RT.NA",hardware_version="4",serial_number="THISISFAKE12345"} 1
+# HELP modem_uptime_seconds The modem uptime, with minute precision
+# TYPE modem_uptime_seconds gauge
+modem_uptime_seconds 137520
+# HELP modem_startup_procedure_status Whether the startup procedure step succeeded (1) or not (0)
+# TYPE modem_startup_procedure_status gauge
+modem_startup_procedure_status{step="acquire_downstream_channel"} 1
+modem_startup_procedure_status{step="boot_state"} 1
+modem_startup_procedure_status{step="configuration_file"} 1
+modem_startup_procedure_status{step="connectivity_state"} 1
+modem_startup_procedure_status{step="docsis_network_access_enabled"} 0
+modem_startup_procedure_status{step="security"} 1
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"modem_info", "modem_uptime_seconds", "modem_startup_procedure_status")
+	assert.NoError(t, err)
+}
+
+func TestCollectorCountsEachEventOnce(t *testing.T) {
+	collector := NewCollector()
+
+	t3 := scrape.EventLog{DateTime: time.Date(2019, 10, 10, 21, 43, 0, 0, time.UTC), EventID: 82000200, EventLevel: 3, Description: "T3 time-out"}
+	t4 := scrape.EventLog{DateTime: time.Date(2019, 10, 10, 21, 44, 0, 0, time.UTC), EventID: 82000300, EventLevel: 3, Description: "T4 time-out"}
+	laterT3 := t3
+	laterT3.DateTime = time.Date(2019, 10, 10, 22, 1, 0, 0, time.UTC)
+
+	assert.NoError(t, collector.Publish(context.Background(), zap.NewNop(), scrape.ModemInformation{EventLog: []scrape.EventLog{}}))
+	assert.NoError(t, collector.Publish(context.Background(), zap.NewNop(), scrape.ModemInformation{EventLog: []scrape.EventLog{t3}}))
+	assert.NoError(t, collector.Publish(context.Background(), zap.NewNop(), scrape.ModemInformation{EventLog: []scrape.EventLog{t3, t4}}))
+	assert.NoError(t, collector.Publish(context.Background(), zap.NewNop(), scrape.ModemInformation{EventLog: []scrape.EventLog{t3, t4, laterT3}}))
+
+	expected := `
+# HELP modem_event_log_events_total The number of event log entries logged since modem-scraper started
+# TYPE modem_event_log_events_total counter
+modem_event_log_events_total{category="ranging",code="R03.0",event_id="82000300",event_level="3",severity="critical"} 1
+modem_event_log_events_total{category="t3_timeout",code="R02.0",event_id="82000200",event_level="3",severity="critical"} 2
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "modem_event_log_events_total")
+	assert.NoError(t, err)
+}
+
+func TestCollectorDoesNotCountEventsLoggedBeforeRestart(t *testing.T) {
+	t3 := scrape.EventLog{DateTime: time.Date(2019, 10, 10, 21, 43, 0, 0, time.UTC), EventID: 82000200, EventLevel: 3, Description: "T3 time-out"}
+	t4 := scrape.EventLog{DateTime: time.Date(2019, 10, 10, 21, 44, 0, 0, time.UTC), EventID: 82000300, EventLevel: 3, Description: "T4 time-out"}
+
+	expected := `
+# HELP modem_event_log_events_total The number of event log entries logged since modem-scraper started
+# TYPE modem_event_log_events_total counter
+modem_event_log_events_total{category="t3_timeout",code="R02.0",event_id="82000200",event_level="3",severity="critical"} 0
+`
+	// Each collector starts afresh, as after a restart.
+	for i := 0; i < 2; i++ {
+		collector := NewCollector()
+		// A failed event log isn't the baseline.
+		assert.NoError(t, collector.Publish(context.Background(), zap.NewNop(), scrape.ModemInformation{FailedSections: []string{scrape.SectionEventLog}}))
+		assert.NoError(t, collector.Publish(context.Background(), zap.NewNop(), scrape.ModemInformation{EventLog: []scrape.EventLog{t3}}))
+		assert.NoError(t, collector.Publish(context.Background(), zap.NewNop(), scrape.ModemInformation{EventLog: []scrape.EventLog{t3}}))
+		err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "modem_event_log_events_total")
+		assert.NoError(t, err)
+
+		assert.NoError(t, collector.Publish(context.Background(), zap.NewNop(), scrape.ModemInformation{EventLog: []scrape.EventLog{t3, t4}}))
+		err = testutil.CollectAndCompare(collector, strings.NewReader(expected+
+			`modem_event_log_events_total{category="ranging",code="R03.0",event_id="82000300",event_level="3",severity="critical"} 1
+`), "modem_event_log_events_total")
+		assert.NoError(t, err)
+	}
+}
+
+func TestCollectorSkipsFailedSections(t *testing.T) {
+	collector := NewCollector()
+
+	t3 := scrape.EventLog{DateTime: time.Date(2019, 10, 10, 21, 43, 0, 0, time.UTC), EventID: 82000200, EventLevel: 3, Description: "T3 time-out"}
+
+	assert.NoError(t, collector.Publish(context.Background(), zap.NewNop(), scrape.ModemInformation{EventLog: []scrape.EventLog{}}))
+	assert.NoError(t, collector.Publish(context.Background(), zap.NewNop(), scrape.ModemInformation{EventLog: []scrape.EventLog{t3}}))
+	// A failed event log must not count the same events again next time.
+	assert.NoError(t, collector.Publish(context.Background(), zap.NewNop(), scrape.ModemInformation{
+		SoftwareInformation: scrape.SoftwareInformation{UptimeMins: 2},
+		FailedSections:      []string{scrape.SectionConnectionStatus, scrape.SectionEventLog},
+	}))
+	assert.NoError(t, collector.Publish(context.Background(), zap.NewNop(), scrape.ModemInformation{EventLog: []scrape.EventLog{t3}}))
+
+	expected := `
+# HELP modem_event_log_events_total The number of event log entries logged since modem-scraper started
+# TYPE modem_event_log_events_total counter
+modem_event_log_events_total{category="t3_timeout",code="R02.0",event_id="82000200",event_level="3",severity="critical"} 1
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "modem_event_log_events_total")
+	assert.NoError(t, err)
+
+	assert.NoError(t, collector.Publish(context.Background(), zap.NewNop(), scrape.ModemInformation{
+		SoftwareInformation: scrape.SoftwareInformation{UptimeMins: 2},
+		FailedSections:      []string{scrape.SectionConnectionStatus},
+	}))
+	expected = `
+# HELP modem_uptime_seconds The modem uptime, with minute precision
+# TYPE modem_uptime_seconds gauge
+modem_uptime_seconds 120
+`
+	err = testutil.CollectAndCompare(collector, strings.NewReader(expected), "modem_uptime_seconds", "modem_startup_procedure_status")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,110 @@
+package prom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ScraperMetrics records the health of modem-scraper itself, to tell
+// a slow or failing modem apart from a broken publishing pipeline.
+// It implements scrape.Observer as well as prometheus.Collector.
+type ScraperMetrics struct {
+	scrapeDuration    prometheus.Gauge
+	scrapeSuccess     prometheus.Gauge
+	pageFetchDuration *prometheus.HistogramVec
+	pageFetchErrors   *prometheus.CounterVec
+	loginFailures     prometheus.Counter
+	parseErrors       *prometheus.CounterVec
+	publishErrors     *prometheus.CounterVec
+}
+
+// NewScraperMetrics creates a ScraperMetrics.
+func NewScraperMetrics() *ScraperMetrics {
+	return &ScraperMetrics{
+		scrapeDuration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "modem_scraper_scrape_duration_seconds",
+			Help: "How long the last scrape of the modem took",
+		}),
+		scrapeSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "modem_scraper_scrape_success",
+			Help: "Whether the last scrape of the modem succeeded (1) or not (0)",
+		}),
+		pageFetchDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "modem_scraper_page_fetch_duration_seconds",
+			Help:    "How long each modem page took to fetch",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 8),
+		}, []string{"page"}),
+		pageFetchErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "modem_scraper_page_fetch_errors_total",
+			Help: "The number of modem page fetches which failed",
+		}, []string{"page"}),
+		loginFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "modem_scraper_login_failures_total",
+			Help: "The number of failed logins to the modem",
+		}),
+		parseErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "modem_scraper_parse_errors_total",
+			Help: "The number of modem page sections which failed to parse",
+		}, []string{"section"}),
+		publishErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "modem_scraper_publish_errors_total",
+			Help: "The number of failed publishes, by publisher",
+		}, []string{"publisher"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *ScraperMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.scrapeDuration.Describe(ch)
+	m.scrapeSuccess.Describe(ch)
+	m.pageFetchDuration.Describe(ch)
+	m.pageFetchErrors.Describe(ch)
+	m.loginFailures.Describe(ch)
+	m.parseErrors.Describe(ch)
+	m.publishErrors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *ScraperMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.scrapeDuration.Collect(ch)
+	m.scrapeSuccess.Collect(ch)
+	m.pageFetchDuration.Collect(ch)
+	m.pageFetchErrors.Collect(ch)
+	m.loginFailures.Collect(ch)
+	m.parseErrors.Collect(ch)
+	m.publishErrors.Collect(ch)
+}
+
+// ObserveScrape implements scrape.Observer.
+func (m *ScraperMetrics) ObserveScrape(duration time.Duration, err error) {
+	m.scrapeDuration.Set(duration.Seconds())
+	if err != nil {
+		m.scrapeSuccess.Set(0)
+	} else {
+		m.scrapeSuccess.Set(1)
+	}
+}
+
+// ObservePageFetch implements scrape.Observer.
+func (m *ScraperMetrics) ObservePageFetch(page string, duration time.Duration, err error) {
+	m.pageFetchDuration.WithLabelValues(page).Observe(duration.Seconds())
+	if err != nil {
+		m.pageFetchErrors.WithLabelValues(page).Inc()
+	}
+}
+
+// ObserveLoginFailure implements scrape.Observer.
+func (m *ScraperMetrics) ObserveLoginFailure() {
+	m.loginFailures.Inc()
+}
+
+// ObserveParseError implements scrape.Observer.
+func (m *ScraperMetrics) ObserveParseError(section string) {
+	m.parseErrors.WithLabelValues(section).Inc()
+}
+
+// ObservePublishError records a failure of the named publisher.
+func (m *ScraperMetrics) ObservePublishError(publisher string) {
+	m.publishErrors.WithLabelValues(publisher).Inc()
+}
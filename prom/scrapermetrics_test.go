@@ -0,0 +1,57 @@
+package prom
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScraperMetricsRecordsScrapeResult(t *testing.T) {
+	metrics := NewScraperMetrics()
+
+	metrics.ObserveScrape(1500*time.Millisecond, nil)
+	metrics.ObserveLoginFailure()
+	metrics.ObserveScrape(2*time.Second, errors.New("login failed"))
+	metrics.ObservePublishError("mqtt")
+
+	expected := `
+# HELP modem_scraper_scrape_duration_seconds How long the last scrape of the modem took
+# TYPE modem_scraper_scrape_duration_seconds gauge
+modem_scraper_scrape_duration_seconds 2
+# HELP modem_scraper_scrape_success Whether the last scrape of the modem succeeded (1) or not (0)
+# TYPE modem_scraper_scrape_success gauge
+modem_scraper_scrape_success 0
+# HELP modem_scraper_login_failures_total The number of failed logins to the modem
+# TYPE modem_scraper_login_failures_total counter
+modem_scraper_login_failures_total 1
+# HELP modem_scraper_publish_errors_total The number of failed publishes, by publisher
+# TYPE modem_scraper_publish_errors_total counter
+modem_scraper_publish_errors_total{publisher="mqtt"} 1
+`
+	err := testutil.CollectAndCompare(metrics, strings.NewReader(expected),
+		"modem_scraper_scrape_duration_seconds",
+		"modem_scraper_scrape_success",
+		"modem_scraper_login_failures_total",
+		"modem_scraper_publish_errors_total",
+	)
+	assert.NoError(t, err)
+}
+
+func TestScraperMetricsRecordsPageFetchErrors(t *testing.T) {
+	metrics := NewScraperMetrics()
+
+	metrics.ObservePageFetch("/cmswinfo.html", 300*time.Millisecond, nil)
+	metrics.ObservePageFetch("/cmeventlog.html", 10*time.Second, errors.New("timeout"))
+
+	expected := `
+# HELP modem_scraper_page_fetch_errors_total The number of modem page fetches which failed
+# TYPE modem_scraper_page_fetch_errors_total counter
+modem_scraper_page_fetch_errors_total{page="/cmeventlog.html"} 1
+`
+	err := testutil.CollectAndCompare(metrics, strings.NewReader(expected), "modem_scraper_page_fetch_errors_total")
+	assert.NoError(t, err)
+}
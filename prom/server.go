@@ -0,0 +1,166 @@
+package prom
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+
+	"github.com/janse180/modem-scraper/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	defaultListenAddress = ":2112"
+	defaultPath          = "/metrics"
+)
+
+// WebConfig is the web configuration file format shared with
+// the Prometheus exporter-toolkit, see
+// https://github.com/prometheus/exporter-toolkit/blob/master/docs/web-configuration.md
+type WebConfig struct {
+	TLSServerConfig TLSServerConfig `yaml:"tls_server_config"`
+	// BasicAuthUsers maps usernames to bcrypt hashed passwords.
+	BasicAuthUsers map[string]string `yaml:"basic_auth_users"`
+}
+
+// TLSServerConfig holds the certificate and key to serve
+// metrics over HTTPS.
+type TLSServerConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// Server serves the metrics endpoint.
+type Server struct {
+	logger   *zap.Logger
+	listener net.Listener
+	server   *http.Server
+	useTLS   bool
+}
+
+// Listen validates the exporter configuration and binds the
+// metrics listener, so that misconfiguration is reported at
+// startup rather than when Prometheus first scrapes.
+func Listen(logger *zap.Logger, conf config.Prometheus, gatherer prometheus.Gatherer) (*Server, error) {
+	listenAddress := conf.ListenAddress
+	if listenAddress == "" {
+		listenAddress = defaultListenAddress
+	}
+	path := conf.Path
+	if path == "" {
+		path = defaultPath
+	}
+
+	webConfig, err := loadWebConfig(conf.WebConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var handler http.Handler = promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+	if len(webConfig.BasicAuthUsers) > 0 {
+		handler = basicAuth(webConfig.BasicAuthUsers, handler)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, handler)
+
+	server := &http.Server{Handler: mux}
+	useTLS := webConfig.TLSServerConfig.CertFile != "" || webConfig.TLSServerConfig.KeyFile != ""
+	if useTLS {
+		certificate, err := tls.LoadX509KeyPair(webConfig.TLSServerConfig.CertFile, webConfig.TLSServerConfig.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading TLS certificate: %s", err.Error())
+		}
+		server.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{certificate},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
+	listener, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on %s: %s", listenAddress, err.Error())
+	}
+
+	logger.Info(fmt.Sprintf("serving metrics on %s%s", listener.Addr(), path),
+		zap.String("op", "prometheus.Listen"),
+		zap.Bool("tls", useTLS),
+		zap.Bool("basic_auth", len(webConfig.BasicAuthUsers) > 0),
+	)
+
+	return &Server{
+		logger:   logger,
+		listener: listener,
+		server:   server,
+		useTLS:   useTLS,
+	}, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Serve serves metrics until the server is closed.
+func (s *Server) Serve() error {
+	var err error
+	if s.useTLS {
+		// The certificate is already loaded into the TLSConfig.
+		err = s.server.ServeTLS(s.listener, "", "")
+	} else {
+		err = s.server.Serve(s.listener)
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+
+	return err
+}
+
+// Close stops the server, including its listener if Serve
+// was never called.
+func (s *Server) Close() error {
+	err := s.server.Close()
+	s.listener.Close()
+
+	return err
+}
+
+func loadWebConfig(path string) (*WebConfig, error) {
+	webConfig := &WebConfig{}
+	if path == "" {
+		return webConfig, nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading web config file: %s", err.Error())
+	}
+	err = yaml.UnmarshalStrict(content, webConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing web config file %s: %s", path, err.Error())
+	}
+
+	return webConfig, nil
+}
+
+func basicAuth(users map[string]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if ok {
+			hash, found := users[username]
+			if found && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", `Basic realm="modem-scraper"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
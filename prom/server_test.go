@@ -0,0 +1,118 @@
+package prom
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/janse180/modem-scraper/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func writeWebConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "modem-scraper")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %s", err)
+	}
+	path := filepath.Join(dir, "web.yml")
+	err = ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatalf("unable to write web config: %s", err)
+	}
+
+	return path
+}
+
+func TestListenServesMetricsOnConfiguredPath(t *testing.T) {
+	server, err := Listen(zap.NewNop(), config.Prometheus{
+		ListenAddress: "127.0.0.1:0",
+		Path:          "/modem/metrics",
+	}, prometheus.NewRegistry())
+	assert.NoError(t, err)
+	go server.Serve()
+	defer server.Close()
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/modem/metrics", server.Addr()))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(fmt.Sprintf("http://%s/metrics", server.Addr()))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestListenRequiresBasicAuthWhenConfigured(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	webConfigFile := writeWebConfig(t, fmt.Sprintf("basic_auth_users:\n  prometheus: %s\n", hash))
+	defer os.RemoveAll(filepath.Dir(webConfigFile))
+
+	server, err := Listen(zap.NewNop(), config.Prometheus{
+		ListenAddress: "127.0.0.1:0",
+		WebConfigFile: webConfigFile,
+	}, prometheus.NewRegistry())
+	assert.NoError(t, err)
+	go server.Serve()
+	defer server.Close()
+
+	address := fmt.Sprintf("http://%s/metrics", server.Addr())
+	tests := []struct {
+		username string
+		password string
+		expected int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"prometheus", "wrong", http.StatusUnauthorized},
+		{"Prometheus", "secret", http.StatusUnauthorized},
+		{"prometheus", "secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", address, nil)
+		if tt.username != "" {
+			req.SetBasicAuth(tt.username, tt.password)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, tt.expected, resp.StatusCode, "%s:%s", tt.username, tt.password)
+	}
+}
+
+func TestListenWithMissingCertificateReturnsError(t *testing.T) {
+	webConfigFile := writeWebConfig(t, "tls_server_config:\n  cert_file: /nonexistent.crt\n  key_file: /nonexistent.key\n")
+	defer os.RemoveAll(filepath.Dir(webConfigFile))
+
+	_, err := Listen(zap.NewNop(), config.Prometheus{
+		ListenAddress: "127.0.0.1:0",
+		WebConfigFile: webConfigFile,
+	}, prometheus.NewRegistry())
+	assert.Error(t, err)
+}
+
+func TestListenWithUnknownWebConfigKeyReturnsError(t *testing.T) {
+	webConfigFile := writeWebConfig(t, "basic_auth_user:\n  prometheus: hash\n")
+	defer os.RemoveAll(filepath.Dir(webConfigFile))
+
+	_, err := Listen(zap.NewNop(), config.Prometheus{
+		ListenAddress: "127.0.0.1:0",
+		WebConfigFile: webConfigFile,
+	}, prometheus.NewRegistry())
+	assert.Error(t, err)
+}
+
+func TestListenOnAddressInUseReturnsError(t *testing.T) {
+	server, err := Listen(zap.NewNop(), config.Prometheus{ListenAddress: "127.0.0.1:0"}, prometheus.NewRegistry())
+	assert.NoError(t, err)
+	defer server.Close()
+
+	_, err = Listen(zap.NewNop(), config.Prometheus{ListenAddress: server.Addr().String()}, prometheus.NewRegistry())
+	assert.Error(t, err)
+}
@@ -0,0 +1,129 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/janse180/modem-scraper/scrape"
+	"go.uber.org/zap"
+)
+
+// Publisher sends scraped ModemInformation somewhere,
+// e.g. InfluxDB or an MQTT broker.
+type Publisher interface {
+	// Name identifies the publisher in logs and metrics.
+	Name() string
+	// Publish sends modemInformation, giving up when ctx is done.
+	Publish(ctx context.Context, modemInformation scrape.ModemInformation) error
+	// Close releases any connection held by the publisher.
+	Close() error
+}
+
+// Result is the outcome of running one Publisher.
+type Result struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+// Registry runs a set of Publishers independently of each other,
+// so that one failing publisher doesn't hold back the rest.
+type Registry struct {
+	logger     *zap.Logger
+	publishers []Publisher
+	parallel   bool
+	timeout    time.Duration
+}
+
+// NewRegistry creates an empty Registry. Each Publisher is given
+// timeout to publish, and all of them run at once when parallel
+// is set, or one after the other otherwise.
+func NewRegistry(logger *zap.Logger, parallel bool, timeout time.Duration) *Registry {
+	return &Registry{
+		logger:   logger,
+		parallel: parallel,
+		timeout:  timeout,
+	}
+}
+
+// Register adds publisher to the registry.
+func (r *Registry) Register(publisher Publisher) {
+	r.publishers = append(r.publishers, publisher)
+}
+
+// Names returns the names of the registered publishers.
+func (r *Registry) Names() []string {
+	names := make([]string, len(r.publishers))
+	for i, publisher := range r.publishers {
+		names[i] = publisher.Name()
+	}
+
+	return names
+}
+
+// Publish runs every registered Publisher, returning their results
+// in the order they were registered.
+func (r *Registry) Publish(ctx context.Context, modemInformation scrape.ModemInformation) []Result {
+	results := make([]Result, len(r.publishers))
+	if !r.parallel {
+		for i, publisher := range r.publishers {
+			results[i] = r.run(ctx, publisher, modemInformation)
+		}
+		return results
+	}
+
+	var wg sync.WaitGroup
+	for i, publisher := range r.publishers {
+		wg.Add(1)
+		go func(i int, publisher Publisher) {
+			defer wg.Done()
+			results[i] = r.run(ctx, publisher, modemInformation)
+		}(i, publisher)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Close closes every registered Publisher, returning an error
+// naming those that failed.
+func (r *Registry) Close() error {
+	failures := []string{}
+	for _, publisher := range r.publishers {
+		err := publisher.Close()
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", publisher.Name(), err.Error()))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("error closing publishers: %s", strings.Join(failures, "; "))
+	}
+
+	return nil
+}
+
+func (r *Registry) run(ctx context.Context, publisher Publisher, modemInformation scrape.ModemInformation) Result {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := publisher.Publish(ctx, modemInformation)
+	elapsed := time.Since(start)
+
+	r.logger.Debug(fmt.Sprintf("finished publishing to %s, took %s", publisher.Name(), elapsed),
+		zap.String("op", "publish.Publish"),
+		zap.Error(err),
+	)
+
+	return Result{
+		Name:     publisher.Name(),
+		Err:      err,
+		Duration: elapsed,
+	}
+}
@@ -0,0 +1,106 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/janse180/modem-scraper/scrape"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// fakePublisher records its calls, and fails with err.
+type fakePublisher struct {
+	name      string
+	err       error
+	closeErr  error
+	delay     time.Duration
+	published int32
+	closed    int32
+}
+
+func (p *fakePublisher) Name() string {
+	return p.name
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, modemInformation scrape.ModemInformation) error {
+	atomic.AddInt32(&p.published, 1)
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return p.err
+}
+
+func (p *fakePublisher) Close() error {
+	atomic.AddInt32(&p.closed, 1)
+	return p.closeErr
+}
+
+func TestRegistryRunsEveryPublisherDespiteFailures(t *testing.T) {
+	influx := &fakePublisher{name: "influxdb", err: errors.New("connection refused")}
+	mqtt := &fakePublisher{name: "mqtt"}
+	boltdb := &fakePublisher{name: "boltdb"}
+
+	registry := NewRegistry(zap.NewNop(), false, time.Second)
+	registry.Register(influx)
+	registry.Register(mqtt)
+	registry.Register(boltdb)
+	assert.Equal(t, []string{"influxdb", "mqtt", "boltdb"}, registry.Names())
+
+	results := registry.Publish(context.Background(), scrape.ModemInformation{})
+	assert.Len(t, results, 3)
+	assert.Equal(t, "influxdb", results[0].Name)
+	assert.EqualError(t, results[0].Err, "connection refused")
+	assert.NoError(t, results[1].Err)
+	assert.NoError(t, results[2].Err)
+	assert.Equal(t, int32(1), mqtt.published)
+	assert.Equal(t, int32(1), boltdb.published)
+}
+
+func TestRegistryPublishesInParallel(t *testing.T) {
+	registry := NewRegistry(zap.NewNop(), true, time.Second)
+	for _, name := range []string{"a", "b", "c"} {
+		registry.Register(&fakePublisher{name: name, delay: 100 * time.Millisecond})
+	}
+
+	start := time.Now()
+	results := registry.Publish(context.Background(), scrape.ModemInformation{})
+	assert.True(t, time.Since(start) < 250*time.Millisecond)
+	for i, name := range []string{"a", "b", "c"} {
+		assert.Equal(t, name, results[i].Name)
+		assert.NoError(t, results[i].Err)
+	}
+}
+
+func TestRegistryTimesOutEachPublisher(t *testing.T) {
+	slow := &fakePublisher{name: "slow", delay: time.Minute}
+	fast := &fakePublisher{name: "fast"}
+
+	registry := NewRegistry(zap.NewNop(), false, 50*time.Millisecond)
+	registry.Register(slow)
+	registry.Register(fast)
+
+	results := registry.Publish(context.Background(), scrape.ModemInformation{})
+	assert.Equal(t, context.DeadlineExceeded, results[0].Err)
+	assert.NoError(t, results[1].Err)
+}
+
+func TestRegistryClosesEveryPublisher(t *testing.T) {
+	influx := &fakePublisher{name: "influxdb", closeErr: errors.New("already closed")}
+	mqtt := &fakePublisher{name: "mqtt"}
+
+	registry := NewRegistry(zap.NewNop(), false, time.Second)
+	registry.Register(influx)
+	registry.Register(mqtt)
+
+	err := registry.Close()
+	assert.EqualError(t, err, "error closing publishers: influxdb: already closed")
+	assert.Equal(t, int32(1), influx.closed)
+	assert.Equal(t, int32(1), mqtt.closed)
+}
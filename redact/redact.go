@@ -0,0 +1,141 @@
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/janse180/modem-scraper/config"
+	"github.com/janse180/modem-scraper/scrape"
+)
+
+// Redaction modes.
+const (
+	// ModeNone leaves the value as it is.
+	ModeNone = "none"
+	// ModeHash replaces the value with a salted hash, which is the
+	// same on every poll, so values can still be told apart.
+	ModeHash = "hash"
+	// ModeMask replaces all but the last few characters with "*".
+	ModeMask = "mask"
+)
+
+const (
+	// hashLength is the number of hex characters of the hash kept.
+	hashLength = 16
+	// maskVisible is the number of letters or digits left unmasked.
+	maskVisible = 4
+)
+
+// descriptionIdentifierPattern matches the MAC addresses appended
+// to event log descriptions, e.g. ";CM-MAC=th:is:is:fa:ke:00;".
+var descriptionIdentifierPattern = regexp.MustCompile(`(CM-MAC|CMTS-MAC)=([^;]*)`)
+
+// Redactor redacts identifiers from ModemInformation, so that it
+// can be shared without revealing which modem it came from.
+type Redactor struct {
+	salt         string
+	macAddress   func(string) string
+	cmtsMAC      func(string) string
+	serialNumber func(string) string
+}
+
+// New creates a Redactor for the given redaction configuration.
+func New(config config.Redaction) (*Redactor, error) {
+	r := &Redactor{salt: config.Salt}
+
+	var err error
+	r.macAddress, err = r.redactor("macAddress", config.MACAddress)
+	if err != nil {
+		return nil, err
+	}
+	r.cmtsMAC, err = r.redactor("cmtsMac", config.CMTSMAC)
+	if err != nil {
+		return nil, err
+	}
+	r.serialNumber, err = r.redactor("serialNumber", config.SerialNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// ModemInformation returns a copy of modemInformation with its
+// identifiers redacted.
+func (r *Redactor) ModemInformation(modemInformation scrape.ModemInformation) scrape.ModemInformation {
+	softwareInformation := &modemInformation.SoftwareInformation
+	softwareInformation.MACAddress = r.macAddress(softwareInformation.MACAddress)
+	softwareInformation.SerialNumber = r.serialNumber(softwareInformation.SerialNumber)
+
+	// The event log is copied, as the slice is shared with the
+	// caller's ModemInformation.
+	if modemInformation.EventLog != nil {
+		eventLog := make([]scrape.EventLog, len(modemInformation.EventLog))
+		for i, event := range modemInformation.EventLog {
+			event.CMMAC = r.macAddress(event.CMMAC)
+			event.CMTSMAC = r.cmtsMAC(event.CMTSMAC)
+			event.Description = r.description(event.Description)
+			eventLog[i] = event
+		}
+		modemInformation.EventLog = eventLog
+	}
+
+	return modemInformation
+}
+
+func (r *Redactor) description(description string) string {
+	return descriptionIdentifierPattern.ReplaceAllStringFunc(description, func(match string) string {
+		keyValue := strings.SplitN(match, "=", 2)
+		if keyValue[0] == "CM-MAC" {
+			return keyValue[0] + "=" + r.macAddress(keyValue[1])
+		}
+		return keyValue[0] + "=" + r.cmtsMAC(keyValue[1])
+	})
+}
+
+func (r *Redactor) redactor(field string, mode string) (func(string) string, error) {
+	switch mode {
+	case "", ModeNone:
+		return func(value string) string { return value }, nil
+	case ModeHash:
+		return r.hash, nil
+	case ModeMask:
+		return mask, nil
+	default:
+		return nil, fmt.Errorf("unknown redaction mode %q for %s, expected one of: none, hash, mask", mode, field)
+	}
+}
+
+// hash ignores case, as modems show MAC addresses in upper case
+// in some places and lower case in others.
+func (r *Redactor) hash(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(r.salt + strings.ToLower(value)))
+	return hex.EncodeToString(sum[:])[:hashLength]
+}
+
+// mask keeps separators, so "TH:IS:IS:FA:KE:00" becomes
+// "**:**:**:**:KE:00".
+func mask(value string) string {
+	visible := 0
+	masked := []rune(value)
+	for i := len(masked) - 1; i >= 0; i-- {
+		if !unicode.IsLetter(masked[i]) && !unicode.IsDigit(masked[i]) {
+			continue
+		}
+		if visible < maskVisible {
+			visible++
+			continue
+		}
+		masked[i] = '*'
+	}
+
+	return string(masked)
+}
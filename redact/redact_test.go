@@ -0,0 +1,68 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/janse180/modem-scraper/config"
+	"github.com/janse180/modem-scraper/scrape"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestModemInformation() scrape.ModemInformation {
+	return scrape.ModemInformation{
+		SoftwareInformation: scrape.SoftwareInformation{
+			MACAddress:   "TH:IS:IS:FA:KE:00",
+			SerialNumber: "THISISFAKE8200",
+		},
+		EventLog: []scrape.EventLog{
+			scrape.EventLog{
+				EventID:     82000200,
+				Description: "No Ranging Response received - T3 time-out;CM-MAC=th:is:is:fa:ke:00;CMTS-MAC=00:01:5c:00:00:01;CM-QOS=1.1;CM-VER=3.0;",
+			}.Decode(),
+		},
+	}
+}
+
+func TestRedactorMasks(t *testing.T) {
+	redactor, err := New(config.Redaction{MACAddress: ModeMask, CMTSMAC: ModeMask, SerialNumber: ModeMask})
+	assert.NoError(t, err)
+
+	actual := redactor.ModemInformation(newTestModemInformation())
+	assert.Equal(t, "**:**:**:**:KE:00", actual.SoftwareInformation.MACAddress)
+	assert.Equal(t, "**********8200", actual.SoftwareInformation.SerialNumber)
+	assert.Equal(t, "**:**:**:**:ke:00", actual.EventLog[0].CMMAC)
+	assert.Equal(t, "**:**:**:**:00:01", actual.EventLog[0].CMTSMAC)
+	assert.Equal(t, "No Ranging Response received - T3 time-out;CM-MAC=**:**:**:**:ke:00;CMTS-MAC=**:**:**:**:00:01;CM-QOS=1.1;CM-VER=3.0;", actual.EventLog[0].Description)
+}
+
+func TestRedactorHashes(t *testing.T) {
+	redactor, err := New(config.Redaction{MACAddress: ModeHash, SerialNumber: ModeHash, Salt: "pepper"})
+	assert.NoError(t, err)
+
+	actual := redactor.ModemInformation(newTestModemInformation())
+	assert.Len(t, actual.SoftwareInformation.MACAddress, hashLength)
+	assert.NotContains(t, actual.SoftwareInformation.SerialNumber, "THISISFAKE")
+	// The same MAC address hashes the same whatever its case.
+	assert.Equal(t, actual.SoftwareInformation.MACAddress, actual.EventLog[0].CMMAC)
+	assert.Contains(t, actual.EventLog[0].Description, "CM-MAC="+actual.EventLog[0].CMMAC+";")
+	// The CMTS MAC address is left alone.
+	assert.Equal(t, "00:01:5c:00:00:01", actual.EventLog[0].CMTSMAC)
+
+	unsalted, err := New(config.Redaction{SerialNumber: ModeHash})
+	assert.NoError(t, err)
+	assert.NotEqual(t, actual.SoftwareInformation.SerialNumber, unsalted.ModemInformation(newTestModemInformation()).SoftwareInformation.SerialNumber)
+}
+
+func TestRedactorLeavesTheOriginalAlone(t *testing.T) {
+	redactor, err := New(config.Redaction{MACAddress: ModeMask})
+	assert.NoError(t, err)
+
+	original := newTestModemInformation()
+	redactor.ModemInformation(original)
+	assert.Equal(t, newTestModemInformation(), original)
+}
+
+func TestNewRejectsUnknownModes(t *testing.T) {
+	_, err := New(config.Redaction{SerialNumber: "scramble"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,367 @@
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/janse180/modem-scraper/config"
+	"go.uber.org/zap"
+)
+
+const (
+	legacyDateTimeLayout = "Mon Jan 2 15:04:05 2006"
+)
+
+func init() {
+	RegisterDriver("sb6183", newSB6183Driver)
+	RegisterDriver("sb6190", newSB6190Driver)
+}
+
+// legacyArrisPages holds the paths of the status pages on
+// the older Arris SB61xx modems.
+type legacyArrisPages struct {
+	ConnectionStatus    string
+	SoftwareInformation string
+	EventLog            string
+	UptimeLabel         string
+}
+
+var sb6183Pages = legacyArrisPages{
+	ConnectionStatus:    "/RgConnect.asp",
+	SoftwareInformation: "/RgSwInfo.asp",
+	EventLog:            "/RgEventLog.asp",
+	UptimeLabel:         "Up Time",
+}
+
+var sb6190Pages = legacyArrisPages{
+	ConnectionStatus:    "/cgi-bin/status",
+	SoftwareInformation: "/cgi-bin/swinfo",
+	EventLog:            "/cgi-bin/eventlog",
+	UptimeLabel:         "System Up Time",
+}
+
+// legacyArrisDriver scrapes the Arris SB6183 and SB6190.
+// Neither requires a login, and both lay their data out in
+// titled tables rather than at fixed positions, so the
+// tables are located by their title row.
+type legacyArrisDriver struct {
+	logger   *zap.Logger
+	conf     config.Modem
+	client   *http.Client
+	location *time.Location
+	pages    legacyArrisPages
+}
+
+func newSB6183Driver(logger *zap.Logger, conf config.Modem, client *http.Client, location *time.Location) Driver {
+	return &legacyArrisDriver{
+		logger:   logger,
+		conf:     conf,
+		client:   client,
+		location: location,
+		pages:    sb6183Pages,
+	}
+}
+
+func newSB6190Driver(logger *zap.Logger, conf config.Modem, client *http.Client, location *time.Location) Driver {
+	return &legacyArrisDriver{
+		logger:   logger,
+		conf:     conf,
+		client:   client,
+		location: location,
+		pages:    sb6190Pages,
+	}
+}
+
+// Login is a no-op, these modems do not require authentication.
+func (d *legacyArrisDriver) Login(ctx context.Context) error {
+	return nil
+}
+
+// FetchConnectionStatus scrapes the connection status page.
+func (d *legacyArrisDriver) FetchConnectionStatus(ctx context.Context) (*ConnectionStatus, error) {
+	doc, err := d.getDocumentFromURL(ctx, d.conf.Url+d.pages.ConnectionStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	return scrapeLegacyConnectionStatus(doc), nil
+}
+
+// FetchSoftwareInformation scrapes the software information page.
+func (d *legacyArrisDriver) FetchSoftwareInformation(ctx context.Context) (*SoftwareInformation, error) {
+	doc, err := d.getDocumentFromURL(ctx, d.conf.Url+d.pages.SoftwareInformation)
+	if err != nil {
+		return nil, err
+	}
+
+	return scrapeLegacySoftwareInformation(doc, d.pages.UptimeLabel), nil
+}
+
+// FetchEventLog scrapes the event log page.
+func (d *legacyArrisDriver) FetchEventLog(ctx context.Context) ([]EventLog, error) {
+	doc, err := d.getDocumentFromURL(ctx, d.conf.Url+d.pages.EventLog)
+	if err != nil {
+		return nil, err
+	}
+
+	return scrapeLegacyEventLogs(d.logger, doc, d.location), nil
+}
+
+// Logout is a no-op, these modems do not hold a session.
+func (d *legacyArrisDriver) Logout(ctx context.Context) error {
+	return nil
+}
+
+func (d *legacyArrisDriver) getDocumentFromURL(ctx context.Context, address string) (doc *goquery.Document, err error) {
+	d.logger.Debug(fmt.Sprintf("grabbing %s", address),
+		zap.String("op", "scrape.getDocumentFromURL"),
+	)
+
+	start := time.Now()
+	defer func() { observePageFetch(address, start, err) }()
+
+	req, err := http.NewRequest("GET", address, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.client.Do(req.WithContext(ctx))
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+	}
+
+	doc, err = goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	elapsed := time.Since(start)
+	d.logger.Debug(fmt.Sprintf("got %s, took %s", address, elapsed),
+		zap.String("op", "scrape.getDocumentFromURL"),
+	)
+
+	return doc, nil
+}
+
+func scrapeLegacyConnectionStatus(doc *goquery.Document) *ConnectionStatus {
+	connectionStatus := ConnectionStatus{
+		StartupProcedure:         scrapeLegacyStartupProcedure(doc),
+		DownstreamBondedChannels: scrapeLegacyDownstreamBondedChannels(doc),
+		UpstreamBondedChannels:   scrapeLegacyUpstreamBondedChannels(doc),
+	}
+
+	return &connectionStatus
+}
+
+func scrapeLegacyStartupProcedure(doc *goquery.Document) StartupProcedure {
+	// Skip the "title" row as well as the "header" row.
+	rows := tableRows(findTableByTitle(doc, "Startup Procedure"), 2)
+	statuses := map[string]Status{}
+	for _, row := range rows {
+		status := Status{}
+		if len(row) > 1 {
+			status.Status = row[1]
+		}
+		if len(row) > 2 {
+			status.Comment = row[2]
+		}
+		statuses[row[0]] = status
+	}
+
+	startupProcedure := StartupProcedure{
+		AcquireDownstreamChannel:   statuses["Acquire Downstream Channel"],
+		ConnectivityState:          statuses["Connectivity State"],
+		BootState:                  statuses["Boot State"],
+		ConfigurationFile:          statuses["Configuration File"],
+		Security:                   statuses["Security"],
+		DOCSISNetworkAccessEnabled: statuses["DOCSIS Network Access Enabled"],
+	}
+
+	return startupProcedure
+}
+
+// The SB61xx tables carry a leading "Channel" counter column
+// and report the symbol rate rather than the width upstream.
+func scrapeLegacyDownstreamBondedChannels(doc *goquery.Document) []DownstreamBondedChannel {
+	rows := tableRows(findTableByTitle(doc, "Downstream Bonded Channels"), 2)
+
+	downstreamBondedChannels := []DownstreamBondedChannel{}
+	for _, row := range rows {
+		if len(row) < 9 {
+			continue
+		}
+		downstreamBondedChannels = append(downstreamBondedChannels, DownstreamBondedChannel{
+			ChannelID:      atoiField(row[3]),
+			LockStatus:     row[1],
+			Modulation:     row[2],
+			FrequencyHz:    atoiField(row[4]),
+			PowerdBmV:      atofField(row[5]),
+			SNRdB:          atofField(row[6]),
+			Corrected:      atoiField(row[7]),
+			Uncorrectables: atoiField(row[8]),
+		})
+	}
+
+	return downstreamBondedChannels
+}
+
+func scrapeLegacyUpstreamBondedChannels(doc *goquery.Document) []UpstreamBondedChannel {
+	rows := tableRows(findTableByTitle(doc, "Upstream Bonded Channels"), 2)
+
+	upstreamBondedChannels := []UpstreamBondedChannel{}
+	for _, row := range rows {
+		if len(row) < 7 {
+			continue
+		}
+		upstreamBondedChannels = append(upstreamBondedChannels, UpstreamBondedChannel{
+			Channel:       atoiField(row[0]),
+			ChannelID:     atoiField(row[3]),
+			LockStatus:    row[1],
+			USChannelType: row[2],
+			FrequencyHz:   atoiField(row[5]),
+			WidthHz:       symbolRateToWidthHz(atoiField(row[4])),
+			PowerdBmV:     atofField(row[6]),
+		})
+	}
+
+	return upstreamBondedChannels
+}
+
+func scrapeLegacySoftwareInformation(doc *goquery.Document, uptimeLabel string) *SoftwareInformation {
+	values := map[string]string{}
+	doc.Find("tr").Each(func(index int, row *goquery.Selection) {
+		cells := row.Children()
+		if cells.Length() == 2 {
+			values[strings.TrimSpace(cells.First().Text())] = strings.TrimSpace(cells.Last().Text())
+		}
+	})
+
+	uptimeString := values[uptimeLabel]
+	softwareInformation := SoftwareInformation{
+		StandardSpecificationCompliant: values["Standard Specification Compliant"],
+		HardwareVersion:                values["Hardware Version"],
+		SoftwareVersion:                values["Software Version"],
+		MACAddress:                     values["Cable Modem MAC Address"],
+		SerialNumber:                   values["Serial Number"],
+		UptimeMins:                     parseUptimeMinutes(uptimeString),
+		UptimeString:                   uptimeString,
+	}
+
+	return &softwareInformation
+}
+
+func scrapeLegacyEventLogs(logger *zap.Logger, doc *goquery.Document, location *time.Location) []EventLog {
+	// Skip the "header" row, the event log table has no title row.
+	rows := tableRows(findTableByTitle(doc, "Time Priority Description"), 1)
+
+	eventLogs := []EventLog{}
+	for _, row := range rows {
+		if len(row) < 3 {
+			continue
+		}
+		eventLogs = append(eventLogs, EventLog{
+			DateTime:    parseEventTime(logger, legacyDateTimeLayout, row[0], location),
+			RawDateTime: row[0],
+			EventLevel:  priorityToEventLevel(row[1]),
+			Description: row[2],
+		})
+	}
+
+	return eventLogs
+}
+
+// findTableByTitle returns the table whose first row reads title,
+// with the cells of that row joined by single spaces.
+func findTableByTitle(doc *goquery.Document, title string) *goquery.Selection {
+	return doc.Find("table").FilterFunction(func(index int, table *goquery.Selection) bool {
+		cells := []string{}
+		table.Find("tr").First().Children().Each(func(_ int, cell *goquery.Selection) {
+			cells = append(cells, strings.Join(strings.Fields(cell.Text()), " "))
+		})
+		return strings.Join(cells, " ") == title
+	}).First()
+}
+
+// tableRows returns the trimmed cell text of each row of table,
+// skipping the first skip rows.
+func tableRows(table *goquery.Selection, skip int) [][]string {
+	rows := [][]string{}
+	table.Find("tr").Each(func(index int, row *goquery.Selection) {
+		if index < skip {
+			return
+		}
+		cells := []string{}
+		row.Children().Each(func(_ int, cell *goquery.Selection) {
+			cells = append(cells, strings.TrimSpace(cell.Text()))
+		})
+		rows = append(rows, cells)
+	})
+
+	return rows
+}
+
+func atoiField(data string) int {
+	data = strings.Split(data, " ")[0]
+	dataInt, _ := strconv.Atoi(data)
+
+	return dataInt
+}
+
+func atofField(data string) float64 {
+	data = strings.Split(data, " ")[0]
+	dataFloat, _ := strconv.ParseFloat(data, 64)
+
+	return dataFloat
+}
+
+// symbolRateToWidthHz converts an upstream symbol rate in
+// Ksym/sec to its channel width, using the DOCSIS roll-off
+// factor of 1.25 (5120 Ksym/sec is a 6.4 MHz channel).
+func symbolRateToWidthHz(kiloSymbolsPerSecond int) int {
+	return kiloSymbolsPerSecond * 1250
+}
+
+var priorityPattern = regexp.MustCompile(`\((\d+)\)`)
+
+// "Critical (3)"
+func priorityToEventLevel(priority string) int {
+	matches := priorityPattern.FindStringSubmatch(priority)
+	if matches == nil {
+		return 0
+	}
+	level, _ := strconv.Atoi(matches[1])
+
+	return level
+}
+
+var uptimePattern = regexp.MustCompile(`^\s*(?:(\d+)\s*d(?:ays?)?:?\s+)?(\d+)\s*h?\s*:\s*(\d+)`)
+
+// parseUptimeMinutes is a more forgiving uptimeToMinutes for the
+// formats used by other models, with the days being optional:
+// "3 d:  04 h: 31  m", "12 days 05:21:09" or "37:58:48"
+func parseUptimeMinutes(uptime string) int {
+	matches := uptimePattern.FindStringSubmatch(uptime)
+	if matches == nil {
+		return 0
+	}
+
+	days, _ := strconv.Atoi(matches[1])
+	hours, _ := strconv.Atoi(matches[2])
+	minutes, _ := strconv.Atoi(matches[3])
+
+	totalMinutes := (days * 24 * 60) + (hours * 60) + minutes
+	return totalMinutes
+}
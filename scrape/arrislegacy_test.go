@@ -0,0 +1,198 @@
+package scrape
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var legacyArrisTests = []struct {
+	model                 string
+	connectionStatusFile  string
+	softwareInfoFile      string
+	eventLogFile          string
+	uptimeLabel           string
+	downstreamChannels    int
+	upstreamChannels      int
+	firstDownstream       DownstreamBondedChannel
+	firstUpstream         UpstreamBondedChannel
+	softwareInformation   SoftwareInformation
+	eventLogs             int
+	firstEventLevel       int
+	lastEventDescription  string
+	configurationFileNote string
+}{
+	{
+		model:                "sb6183",
+		connectionStatusFile: "../testdata/sb6183/RgConnect.asp",
+		softwareInfoFile:     "../testdata/sb6183/RgSwInfo.asp",
+		eventLogFile:         "../testdata/sb6183/RgEventLog.asp",
+		uptimeLabel:          sb6183Pages.UptimeLabel,
+		downstreamChannels:   16,
+		upstreamChannels:     4,
+		firstDownstream: DownstreamBondedChannel{
+			ChannelID:      17,
+			LockStatus:     "Locked",
+			Modulation:     "QAM256",
+			FrequencyHz:    507000000,
+			PowerdBmV:      5.3,
+			SNRdB:          38.2,
+			Corrected:      0,
+			Uncorrectables: 0,
+		},
+		firstUpstream: UpstreamBondedChannel{
+			Channel:       1,
+			ChannelID:     1,
+			LockStatus:    "Locked",
+			USChannelType: "ATDMA",
+			FrequencyHz:   36500000,
+			WidthHz:       6400000,
+			PowerdBmV:     44.9,
+		},
+		softwareInformation: SoftwareInformation{
+			StandardSpecificationCompliant: "DOCSIS 3.0",
+			HardwareVersion:                "1",
+			SoftwareVersion:                "D30CM-OSPREY-2.4.0.1-GA-02-NOSH",
+			MACAddress:                     "TH:IS:IS:FA:KE:00",
+			SerialNumber:                   "THISISFAKE6183",
+			UptimeMins:                     4591,
+			UptimeString:                   "3 d:  04 h: 31  m",
+		},
+		eventLogs:             4,
+		firstEventLevel:       3,
+		lastEventDescription:  "Started Unicast Maintenance Ranging - No Response received - T3 time-out;CM-MAC=th:is:is:fa:ke:00;CMTS-MAC=00:01:5c:00:00:01;CM-QOS=1.1;CM-VER=3.0;",
+		configurationFileNote: "d11_m_sb6183_basic_2.cm",
+	},
+	{
+		model:                "sb6190",
+		connectionStatusFile: "../testdata/sb6190/status.html",
+		softwareInfoFile:     "../testdata/sb6190/swinfo.html",
+		eventLogFile:         "../testdata/sb6190/eventlog.html",
+		uptimeLabel:          sb6190Pages.UptimeLabel,
+		downstreamChannels:   32,
+		upstreamChannels:     4,
+		firstDownstream: DownstreamBondedChannel{
+			ChannelID:      1,
+			LockStatus:     "Locked",
+			Modulation:     "QAM256",
+			FrequencyHz:    483000000,
+			PowerdBmV:      4.9,
+			SNRdB:          38.4,
+			Corrected:      12,
+			Uncorrectables: 0,
+		},
+		firstUpstream: UpstreamBondedChannel{
+			Channel:       1,
+			ChannelID:     5,
+			LockStatus:    "Locked",
+			USChannelType: "ATDMA",
+			FrequencyHz:   17300000,
+			WidthHz:       6400000,
+			PowerdBmV:     46.1,
+		},
+		softwareInformation: SoftwareInformation{
+			StandardSpecificationCompliant: "DOCSIS 3.0",
+			HardwareVersion:                "6",
+			SoftwareVersion:                "9.1.103AA65L",
+			MACAddress:                     "TH:IS:IS:FA:KE:01",
+			SerialNumber:                   "THISISFAKE6190",
+			UptimeMins:                     17601,
+			UptimeString:                   "12 days 05:21:09",
+		},
+		eventLogs:             3,
+		firstEventLevel:       3,
+		lastEventDescription:  "TLV-11 - unrecognized OID;CM-MAC=th:is:is:fa:ke:00;CMTS-MAC=00:01:5c:00:00:01;CM-QOS=1.1;CM-VER=3.0;",
+		configurationFileNote: "d11_m_sb6190_basic.cm",
+	},
+}
+
+func TestScrapeLegacyConnectionStatus(t *testing.T) {
+	for _, tt := range legacyArrisTests {
+		t.Run(tt.model, func(t *testing.T) {
+			doc := getDocumentFromTestFile(t, tt.connectionStatusFile)
+
+			actual := scrapeLegacyConnectionStatus(doc)
+			assert.NotNil(t, actual)
+			assert.Equal(t, Status{Status: "OK", Comment: "Operational"}, actual.StartupProcedure.ConnectivityState)
+			assert.Equal(t, Status{Status: "OK", Comment: tt.configurationFileNote}, actual.StartupProcedure.ConfigurationFile)
+			assert.Equal(t, Status{Status: "Allowed", Comment: ""}, actual.StartupProcedure.DOCSISNetworkAccessEnabled)
+			assert.Len(t, actual.DownstreamBondedChannels, tt.downstreamChannels)
+			assert.Equal(t, tt.firstDownstream, actual.DownstreamBondedChannels[0])
+			assert.Len(t, actual.UpstreamBondedChannels, tt.upstreamChannels)
+			assert.Equal(t, tt.firstUpstream, actual.UpstreamBondedChannels[0])
+		})
+	}
+}
+
+func TestScrapeLegacySoftwareInformation(t *testing.T) {
+	for _, tt := range legacyArrisTests {
+		t.Run(tt.model, func(t *testing.T) {
+			doc := getDocumentFromTestFile(t, tt.softwareInfoFile)
+
+			actual := scrapeLegacySoftwareInformation(doc, tt.uptimeLabel)
+			assert.NotNil(t, actual)
+			assert.Equal(t, &tt.softwareInformation, actual)
+		})
+	}
+}
+
+func TestScrapeLegacyEventLogs(t *testing.T) {
+	for _, tt := range legacyArrisTests {
+		t.Run(tt.model, func(t *testing.T) {
+			doc := getDocumentFromTestFile(t, tt.eventLogFile)
+
+			actual := scrapeLegacyEventLogs(zap.NewNop(), doc, time.UTC)
+			assert.Len(t, actual, tt.eventLogs)
+			assert.True(t, actual[0].DateTime.IsZero())
+			assert.Equal(t, "Time Not Established", actual[0].RawDateTime)
+			assert.Equal(t, time.UTC, actual[len(actual)-1].DateTime.Location())
+			assert.False(t, actual[len(actual)-1].DateTime.IsZero())
+			assert.Equal(t, tt.firstEventLevel, actual[0].EventLevel)
+			assert.Equal(t, tt.lastEventDescription, actual[len(actual)-1].Description)
+		})
+	}
+}
+
+func TestParseUptimeMinutes(t *testing.T) {
+	tests := []struct {
+		uptime   string
+		expected int
+	}{
+		{"3 d:  04 h: 31  m", 4591},
+		{"0 d:  00 h: 05  m", 5},
+		{"12 days 05:21:09", 17601},
+		{"1 days 14h:12m:38s.00", 2292},
+		{"0 days 05h:14m:32s", 314},
+		{"37:58:48", 2278},
+		{"", 0},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, parseUptimeMinutes(tt.uptime), tt.uptime)
+	}
+}
+
+func TestPriorityToEventLevel(t *testing.T) {
+	assert.Equal(t, 3, priorityToEventLevel("Critical (3)"))
+	assert.Equal(t, 6, priorityToEventLevel("Notice (6)"))
+	assert.Equal(t, 0, priorityToEventLevel("Unknown"))
+}
+
+func getDocumentFromTestFile(t *testing.T, filePath string) *goquery.Document {
+	fileReader, err := os.Open(filePath)
+	if err != nil {
+		t.Fatalf("unable to open file for reading: [%s]", filePath)
+	}
+	defer fileReader.Close()
+
+	doc, err := goquery.NewDocumentFromReader(fileReader)
+	if err != nil {
+		t.Fatalf("unable to generate goquery document from file: [%s]", filePath)
+	}
+
+	return doc
+}
@@ -0,0 +1,64 @@
+package scrape
+
+import (
+	"sync"
+	"time"
+)
+
+// BootClock tracks when the modem booted. The uptime is only given
+// to the minute, and polls aren't in step with it, so an estimate
+// made afresh each poll moves between adjacent minutes. BootClock
+// keeps its estimate until the modem has restarted.
+type BootClock struct {
+	mu         sync.Mutex
+	bootTime   time.Time
+	uptimeMins int
+}
+
+// BootTime returns when the modem booted, given modemInformation
+// scraped at now, or now if its uptime isn't known.
+func (b *BootClock) BootTime(now time.Time, modemInformation ModemInformation) time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	uptimeMins, ok := uptimeOf(modemInformation)
+	if !ok {
+		return now
+	}
+
+	// An uptime which went down, or an estimate more than the
+	// uptime's precision off, means the modem restarted in between.
+	estimate := estimateBootTime(now, modemInformation)
+	if b.bootTime.IsZero() || uptimeMins < b.uptimeMins || absDuration(estimate.Sub(b.bootTime)) > time.Minute {
+		b.bootTime = estimate
+	}
+	b.uptimeMins = uptimeMins
+
+	return b.bootTime
+}
+
+// estimateBootTime is now less the uptime, to the minute, or now if
+// the uptime isn't known.
+func estimateBootTime(now time.Time, modemInformation ModemInformation) time.Time {
+	uptimeMins, ok := uptimeOf(modemInformation)
+	if !ok {
+		return now
+	}
+
+	return now.Add(-time.Duration(uptimeMins) * time.Minute).Truncate(time.Minute)
+}
+
+func uptimeOf(modemInformation ModemInformation) (int, bool) {
+	if !modemInformation.Scraped(SectionSoftwareInformation) || modemInformation.SoftwareInformation.UptimeMins <= 0 {
+		return 0, false
+	}
+
+	return modemInformation.SoftwareInformation.UptimeMins, true
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,50 @@
+package scrape
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func withUptime(uptimeMins int) ModemInformation {
+	return ModemInformation{SoftwareInformation: SoftwareInformation{UptimeMins: uptimeMins}}
+}
+
+func TestBootClockIsSteadyAcrossTheMinute(t *testing.T) {
+	// Booted at 10:00:30, so the uptime ticks over at :30.
+	booted := time.Date(2019, 10, 11, 10, 0, 30, 0, time.UTC)
+	clock := &BootClock{}
+
+	// 11:00:50 with 60 minutes up estimates 10:00, and 11:02:10 with
+	// 61 minutes up estimates 10:01.
+	first := clock.BootTime(booted.Add(60*time.Minute+20*time.Second), withUptime(60))
+	second := clock.BootTime(booted.Add(61*time.Minute+40*time.Second), withUptime(61))
+	assert.Equal(t, time.Date(2019, 10, 11, 10, 0, 0, 0, time.UTC), first)
+	assert.Equal(t, first, second)
+	assert.NotEqual(t, first, estimateBootTime(booted.Add(61*time.Minute+40*time.Second), withUptime(61)))
+}
+
+func TestBootClockResetsWhenTheModemRestarts(t *testing.T) {
+	booted := time.Date(2019, 10, 11, 10, 0, 30, 0, time.UTC)
+	clock := &BootClock{}
+	clock.BootTime(booted.Add(60*time.Minute), withUptime(60))
+
+	// The uptime went down.
+	now := booted.Add(70 * time.Minute)
+	assert.Equal(t, time.Date(2019, 10, 11, 11, 5, 0, 0, time.UTC), clock.BootTime(now, withUptime(5)))
+
+	// The uptime went up, but by less than the time between polls.
+	now = now.Add(3 * time.Hour)
+	assert.Equal(t, time.Date(2019, 10, 11, 13, 40, 0, 0, time.UTC), clock.BootTime(now, withUptime(30)))
+}
+
+func TestBootClockWithoutUptime(t *testing.T) {
+	now := time.Date(2019, 10, 11, 10, 0, 30, 0, time.UTC)
+	clock := &BootClock{}
+	assert.Equal(t, now, clock.BootTime(now, withUptime(0)))
+
+	failed := withUptime(60)
+	failed.FailedSections = []string{SectionSoftwareInformation}
+	assert.Equal(t, now, clock.BootTime(now, failed))
+}
@@ -0,0 +1,84 @@
+package scrape
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/janse180/modem-scraper/config"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// newModemClient creates the http.Client used for every request to
+// the modem, so that connections are reused across page fetches.
+//
+// Modems only offer self-signed certificates (and the SB8200 an
+// ancient one which can't be replaced), so certificates are not
+// verified against any CA. Instead, the certificate can be pinned
+// by its SHA-256 fingerprint. Either way, this only applies to the
+// modem client, not to http.DefaultTransport.
+func newModemClient(conf config.Modem) (*http.Client, error) {
+	timeout := conf.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	if conf.CertificateFingerprint != "" {
+		fingerprint, err := parseFingerprint(conf.CertificateFingerprint)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.VerifyPeerCertificate = verifyFingerprint(fingerprint)
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: timeout,
+		MaxIdleConns:        4,
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}, nil
+}
+
+// parseFingerprint accepts a hex SHA-256 fingerprint, with or without
+// colons, e.g. as printed by `openssl x509 -fingerprint -sha256`.
+func parseFingerprint(fingerprint string) ([]byte, error) {
+	decoded, err := hex.DecodeString(strings.Replace(fingerprint, ":", "", -1))
+	if err != nil || len(decoded) != sha256.Size {
+		return nil, fmt.Errorf("invalid certificate fingerprint %q, expected a hex SHA-256 digest", fingerprint)
+	}
+
+	return decoded, nil
+}
+
+func verifyFingerprint(fingerprint []byte) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("modem presented no certificate")
+		}
+		actual := sha256.Sum256(rawCerts[0])
+		if !bytes.Equal(actual[:], fingerprint) {
+			return fmt.Errorf("modem certificate fingerprint %s does not match the pinned fingerprint", hex.EncodeToString(actual[:]))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,98 @@
+package scrape
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/janse180/modem-scraper/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func newTLSTestServer(t *testing.T) (*httptest.Server, string) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	fingerprint := sha256.Sum256(server.Certificate().Raw)
+
+	return server, hex.EncodeToString(fingerprint[:])
+}
+
+func TestModemClientAcceptsSelfSignedCertificate(t *testing.T) {
+	server, _ := newTLSTestServer(t)
+	defer server.Close()
+
+	client, err := newModemClient(config.Modem{})
+	assert.NoError(t, err)
+
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+}
+
+func TestModemClientAcceptsPinnedCertificate(t *testing.T) {
+	server, fingerprint := newTLSTestServer(t)
+	defer server.Close()
+
+	// Colon separated upper case, as printed by openssl.
+	pairs := []string{}
+	for i := 0; i < len(fingerprint); i += 2 {
+		pairs = append(pairs, strings.ToUpper(fingerprint[i:i+2]))
+	}
+
+	client, err := newModemClient(config.Modem{CertificateFingerprint: strings.Join(pairs, ":")})
+	assert.NoError(t, err)
+
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+}
+
+func TestModemClientRejectsMismatchedCertificate(t *testing.T) {
+	server, _ := newTLSTestServer(t)
+	defer server.Close()
+
+	client, err := newModemClient(config.Modem{CertificateFingerprint: strings.Repeat("00", sha256.Size)})
+	assert.NoError(t, err)
+
+	_, err = client.Get(server.URL)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match the pinned fingerprint")
+}
+
+func TestModemClientWithInvalidFingerprintReturnsError(t *testing.T) {
+	_, err := newModemClient(config.Modem{CertificateFingerprint: "not-a-fingerprint"})
+	assert.Error(t, err)
+
+	_, err = NewDriver(zap.NewNop(), config.Modem{CertificateFingerprint: "abcd"})
+	assert.Error(t, err)
+}
+
+func TestModemClientTimeout(t *testing.T) {
+	client, err := newModemClient(config.Modem{})
+	assert.NoError(t, err)
+	assert.Equal(t, defaultTimeout, client.Timeout)
+
+	client, err = newModemClient(config.Modem{Timeout: 5 * time.Second})
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, client.Timeout)
+}
+
+func TestModemClientDoesNotModifyDefaultTransport(t *testing.T) {
+	server, _ := newTLSTestServer(t)
+	defer server.Close()
+
+	driver, err := NewDriver(zap.NewNop(), config.Modem{Url: server.URL})
+	assert.NoError(t, err)
+	_, err = driver.FetchConnectionStatus(context.Background())
+	assert.NoError(t, err)
+
+	_, err = http.Get(server.URL)
+	assert.Error(t, err)
+}
@@ -4,7 +4,6 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	_ "github.com/influxdata/influxdb1-client" // this is important because of a bug in go mod
 	client "github.com/influxdata/influxdb1-client/v2"
-	"github.com/prometheus/client_golang/prometheus"
 )
 
 // ConnectionStatus holds all info from /cmconnectionstatus.html.
@@ -14,42 +13,23 @@ type ConnectionStatus struct {
 	UpstreamBondedChannels   []UpstreamBondedChannel
 }
 
-func init() {
-	prometheus.MustRegister(UpstreamBondedChannelPowerGauge)
-	prometheus.MustRegister(DownstreamBondedChannelPowerGauge)
-	prometheus.MustRegister(DownstreamBondedChannelSNRGauge)
-	prometheus.MustRegister(DownstreamBondedChannelCorrectedGauge)
-	prometheus.MustRegister(DownstreamBondedChannelUncorrectedGauge)
-}
-
-func (c ConnectionStatus) UpdateGauge() {
-
-	for _, channel := range c.DownstreamBondedChannels {
-		channel.UpdateGauge()
-	}
-	for _, channel := range c.UpstreamBondedChannels {
-		channel.UpdateGauge()
-	}
-
-}
-
 // ToInfluxPoints converts ConnectionStatus to "points"
-func (c ConnectionStatus) ToInfluxPoints() ([]*client.Point, error) {
+func (c ConnectionStatus) ToInfluxPoints(options InfluxOptions) ([]*client.Point, error) {
 	var points []*client.Point
 
-	influxPoints, err := c.StartupProcedure.ToInfluxPoints()
+	influxPoints, err := c.StartupProcedure.ToInfluxPoints(options)
 	if err != nil {
 		return nil, err
 	}
 	points = append(points, influxPoints...)
 
-	influxPoints, err = buildDownstreamBondedChannelPoints(c.DownstreamBondedChannels)
+	influxPoints, err = buildDownstreamBondedChannelPoints(c.DownstreamBondedChannels, options)
 	if err != nil {
 		return nil, err
 	}
 	points = append(points, influxPoints...)
 
-	influxPoints, err = buildUpstreamBondedChannelPoints(c.UpstreamBondedChannels)
+	influxPoints, err = buildUpstreamBondedChannelPoints(c.UpstreamBondedChannels, options)
 	if err != nil {
 		return nil, err
 	}
@@ -68,11 +48,11 @@ func scrapeConnectionStatus(doc *goquery.Document) *ConnectionStatus {
 	return &connectionStatus
 }
 
-func buildDownstreamBondedChannelPoints(channels []DownstreamBondedChannel) ([]*client.Point, error) {
+func buildDownstreamBondedChannelPoints(channels []DownstreamBondedChannel, options InfluxOptions) ([]*client.Point, error) {
 	var points []*client.Point
 
 	for _, channel := range channels {
-		influxPoints, err := channel.ToInfluxPoints()
+		influxPoints, err := channel.ToInfluxPoints(options)
 		if err != nil {
 			return nil, err
 		}
@@ -82,11 +62,11 @@ func buildDownstreamBondedChannelPoints(channels []DownstreamBondedChannel) ([]*
 	return points, nil
 }
 
-func buildUpstreamBondedChannelPoints(channels []UpstreamBondedChannel) ([]*client.Point, error) {
+func buildUpstreamBondedChannelPoints(channels []UpstreamBondedChannel, options InfluxOptions) ([]*client.Point, error) {
 	var points []*client.Point
 
 	for _, channel := range channels {
-		influxPoints, err := channel.ToInfluxPoints()
+		influxPoints, err := channel.ToInfluxPoints(options)
 		if err != nil {
 			return nil, err
 		}
@@ -3,12 +3,10 @@ package scrape
 import (
 	"fmt"
 	"strconv"
-	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	_ "github.com/influxdata/influxdb1-client" // this is important because of a bug in go mod
 	client "github.com/influxdata/influxdb1-client/v2"
-	"github.com/prometheus/client_golang/prometheus"
 )
 
 // DownstreamBondedChannel holds all info from the
@@ -25,77 +23,8 @@ type DownstreamBondedChannel struct {
 	Uncorrectables int
 }
 
-var (
-	DownstreamBondedChannelPowerGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "downstream_bonded_channel_powerdbmv",
-		Help: "The downstream bonded channel power",
-	}, []string{
-		"ChannelID",
-		"LockStatus",
-		"Modulation",
-		"FrequencyHz",
-	})
-	DownstreamBondedChannelSNRGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "downstream_bonded_channel_snrdb",
-		Help: "The downstream bonded channel snr",
-	}, []string{
-		"ChannelID",
-		"LockStatus",
-		"Modulation",
-		"FrequencyHz",
-	})
-	DownstreamBondedChannelCorrectedGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "downstream_bonded_channel_error_corrected",
-		Help: "The downstream bonded channel corrected errors",
-	}, []string{
-		"ChannelID",
-		"LockStatus",
-		"Modulation",
-		"FrequencyHz",
-	})
-	DownstreamBondedChannelUncorrectedGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "downstream_bonded_channel_error_uncorrected",
-		Help: "The downstream bonded channel uncorrected errors",
-	}, []string{
-		"ChannelID",
-		"LockStatus",
-		"Modulation",
-		"FrequencyHz",
-	})
-)
-
-func (d DownstreamBondedChannel) UpdateGauge() error {
-
-	DownstreamBondedChannelPowerGauge.WithLabelValues(
-		strconv.Itoa(d.ChannelID),
-		d.LockStatus,
-		d.Modulation,
-		strconv.Itoa(d.FrequencyHz)).Set(d.PowerdBmV)
-
-	DownstreamBondedChannelSNRGauge.WithLabelValues(
-		strconv.Itoa(d.ChannelID),
-		d.LockStatus,
-		d.Modulation,
-		strconv.Itoa(d.FrequencyHz)).Set(d.SNRdB)
-
-	DownstreamBondedChannelCorrectedGauge.WithLabelValues(
-		strconv.Itoa(d.ChannelID),
-		d.LockStatus,
-		d.Modulation,
-		strconv.Itoa(d.FrequencyHz)).Set(float64(d.Corrected))
-
-	DownstreamBondedChannelUncorrectedGauge.WithLabelValues(
-		strconv.Itoa(d.ChannelID),
-		d.LockStatus,
-		d.Modulation,
-		strconv.Itoa(d.FrequencyHz)).Set(float64(d.Uncorrectables))
-
-	return nil
-
-}
-
 // ToInfluxPoints converts DownstreamBondedChannel to "points"
-func (d DownstreamBondedChannel) ToInfluxPoints() ([]*client.Point, error) {
+func (d DownstreamBondedChannel) ToInfluxPoints(options InfluxOptions) ([]*client.Point, error) {
 	var points []*client.Point
 
 	channelIDString := strconv.Itoa(d.ChannelID)
@@ -103,15 +32,21 @@ func (d DownstreamBondedChannel) ToInfluxPoints() ([]*client.Point, error) {
 		"channel_id": channelIDString,
 	}
 	fields := map[string]interface{}{
-		"lock_status":    d.LockStatus,
-		"modulation":     d.Modulation,
 		"frequency_hz":   d.FrequencyHz,
 		"power_dbmv":     d.PowerdBmV,
 		"snr_db":         d.SNRdB,
 		"corrected":      d.Corrected,
 		"uncorrectables": d.Uncorrectables,
 	}
-	point, err := client.NewPoint("downstream_bonded_channel", tags, fields, time.Now())
+	if options.Schema == InfluxSchemaV2 {
+		tags["lock_status"] = d.LockStatus
+		tags["modulation"] = d.Modulation
+		fields["lock_status_ok"] = okField(d.LockStatus == "Locked")
+	} else {
+		fields["lock_status"] = d.LockStatus
+		fields["modulation"] = d.Modulation
+	}
+	point, err := client.NewPoint("downstream_bonded_channel", tags, fields, options.Time)
 	if err != nil {
 		return nil, fmt.Errorf("error generating points data for DownstreamBondedChannel: %s", err.Error())
 	}
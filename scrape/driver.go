@@ -0,0 +1,91 @@
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/janse180/modem-scraper/config"
+	"go.uber.org/zap"
+)
+
+// DefaultModel is the modem model used when modem.model is not set.
+const DefaultModel = "sb8200"
+
+// Driver knows how to pull status information from a
+// particular modem model. Requests are cancelled with ctx.
+type Driver interface {
+	// Login authenticates against the modem, if the model requires it.
+	Login(ctx context.Context) error
+	// FetchConnectionStatus retrieves the startup procedure and
+	// bonded channel information.
+	FetchConnectionStatus(ctx context.Context) (*ConnectionStatus, error)
+	// FetchSoftwareInformation retrieves the hardware/software
+	// versions and uptime.
+	FetchSoftwareInformation(ctx context.Context) (*SoftwareInformation, error)
+	// FetchEventLog retrieves the modem's event log.
+	FetchEventLog(ctx context.Context) ([]EventLog, error)
+	// Logout releases any session held on the modem.
+	Logout(ctx context.Context) error
+}
+
+// Rebooter is implemented by Drivers which can restart the modem.
+type Rebooter interface {
+	// Reboot restarts the modem. It must be logged in.
+	Reboot(ctx context.Context) error
+}
+
+// DriverFactory creates a Driver for the given modem configuration,
+// which makes all of its requests through client, and reads event
+// log times in location.
+type DriverFactory func(logger *zap.Logger, conf config.Modem, client *http.Client, location *time.Location) Driver
+
+var drivers = map[string]DriverFactory{}
+
+// RegisterDriver makes a Driver available under the given model name.
+func RegisterDriver(model string, factory DriverFactory) {
+	drivers[strings.ToLower(model)] = factory
+}
+
+// NewDriver creates the Driver for the model named in the
+// modem configuration.
+func NewDriver(logger *zap.Logger, conf config.Modem) (Driver, error) {
+	model := strings.ToLower(conf.Model)
+	if model == "" {
+		model = DefaultModel
+	}
+
+	factory, ok := drivers[model]
+	if !ok {
+		return nil, fmt.Errorf("unsupported modem model %q, expected one of: %s", conf.Model, strings.Join(Models(), ", "))
+	}
+
+	client, err := newModemClient(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	location := time.Local
+	if conf.Timezone != "" {
+		location, err = time.LoadLocation(conf.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown modem timezone %q: %s", conf.Timezone, err.Error())
+		}
+	}
+
+	return factory(logger, conf, client, location), nil
+}
+
+// Models returns the names of all registered modem models.
+func Models() []string {
+	models := make([]string, 0, len(drivers))
+	for model := range drivers {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	return models
+}
@@ -0,0 +1,33 @@
+package scrape
+
+import (
+	"testing"
+
+	"github.com/janse180/modem-scraper/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestNewDriverDefaultsToSB8200(t *testing.T) {
+	driver, err := NewDriver(zap.NewNop(), config.Modem{})
+	assert.NoError(t, err)
+	assert.IsType(t, &sb8200Driver{}, driver)
+}
+
+func TestNewDriverIsCaseInsensitive(t *testing.T) {
+	driver, err := NewDriver(zap.NewNop(), config.Modem{Model: "SB8200"})
+	assert.NoError(t, err)
+	assert.IsType(t, &sb8200Driver{}, driver)
+}
+
+func TestNewDriverWithUnknownModelReturnsError(t *testing.T) {
+	driver, err := NewDriver(zap.NewNop(), config.Modem{Model: "tc4400"})
+	assert.Error(t, err)
+	assert.Nil(t, driver)
+}
+
+func TestNewDriverWithUnknownTimezoneReturnsError(t *testing.T) {
+	driver, err := NewDriver(zap.NewNop(), config.Modem{Timezone: "Mars/Olympus_Mons"})
+	assert.Error(t, err)
+	assert.Nil(t, driver)
+}
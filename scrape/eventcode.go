@@ -0,0 +1,177 @@
+package scrape
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EventCode describes a DOCSIS event ID, as catalogued in Annex D
+// of the DOCSIS OSSI specification.
+type EventCode struct {
+	// Code is the event code as written in the specification,
+	// e.g. "R02.0".
+	Code string
+	// Category groups related events, e.g. "t3_timeout" or "dhcp".
+	Category string
+	// Name is a short description of the event, or empty if the
+	// event isn't in the built-in catalogue.
+	Name string
+}
+
+// Event categories.
+const (
+	EventCategoryT3Timeout        = "t3_timeout"
+	EventCategoryT4Timeout        = "t4_timeout"
+	EventCategoryRanging          = "ranging"
+	EventCategorySync             = "sync"
+	EventCategoryDHCP             = "dhcp"
+	EventCategoryToD              = "tod"
+	EventCategoryRegistration     = "registration"
+	EventCategorySoftwareDownload = "software_download"
+	EventCategoryBPI              = "bpi"
+	EventCategoryDCC              = "dcc"
+	EventCategoryOther            = "other"
+)
+
+// eventCatalogue holds the events which are most often seen in
+// modem logs, or worth telling apart from the rest of their group.
+var eventCatalogue = map[int]EventCode{
+	82000100: {Category: EventCategoryRanging, Name: "No Maintenance Broadcasts for Ranging opportunities Received - T2 time-out"},
+	82000200: {Category: EventCategoryT3Timeout, Name: "No Ranging Response received - T3 time-out"},
+	82000300: {Category: EventCategoryRanging, Name: "Ranging Request Retries exhausted"},
+	82000400: {Category: EventCategoryT4Timeout, Name: "Received Response to Broadcast Maintenance Request, But no Unicast Maintenance opportunities received - T4 time out"},
+	82000500: {Category: EventCategoryT3Timeout, Name: "Started Unicast Maintenance Ranging - No Response received - T3 time-out"},
+	82000600: {Category: EventCategoryRanging, Name: "Unicast Maintenance Ranging attempted - No response - Retries exhausted"},
+	82000700: {Category: EventCategoryRanging, Name: "Unicast Ranging Received Abort Response - Re-initializing MAC"},
+	84000100: {Category: EventCategorySync, Name: "SYNC Timing Synchronization failure - Failed to acquire QAM/QPSK symbol timing"},
+	84000200: {Category: EventCategorySync, Name: "SYNC Timing Synchronization failure - Failed to acquire FEC framing"},
+	84000300: {Category: EventCategorySync, Name: "SYNC Timing Synchronization failure - Acquired FEC framing - Failed to acquire MPEG2 Sync"},
+	84000400: {Category: EventCategorySync, Name: "SYNC Timing Synchronization failure - Failed to acquire MAC framing"},
+	84000500: {Category: EventCategorySync, Name: "SYNC Timing Synchronization failure - Failed to receive MAC SYNC frame within time-out period"},
+	84000600: {Category: EventCategorySync, Name: "SYNC Timing Synchronization failure - Loss of Sync"},
+	68000100: {Category: EventCategoryDHCP, Name: "DHCP FAILED - Discover sent, no offer received"},
+	68000200: {Category: EventCategoryDHCP, Name: "DHCP FAILED - Request sent, No response"},
+	68010100: {Category: EventCategoryDHCP, Name: "DHCP RENEW sent - No response for IPv4"},
+	68010200: {Category: EventCategoryDHCP, Name: "DHCP REBIND sent - No response for IPv4"},
+	68010300: {Category: EventCategoryDHCP, Name: "DHCP RENEW WARNING - Field invalid in response v4 option"},
+	68000401: {Category: EventCategoryToD, Name: "ToD request sent - No Response received"},
+	68000402: {Category: EventCategoryToD, Name: "ToD Response received - Invalid data format"},
+	69010100: {Category: EventCategorySoftwareDownload, Name: "SW Download INIT - Via NMS"},
+	69010200: {Category: EventCategorySoftwareDownload, Name: "SW Download INIT - Via Config file"},
+	73040100: {Category: EventCategoryRegistration, Name: "TLV-11 - unrecognized OID"},
+}
+
+// prefixCategories categorise events which aren't in the catalogue
+// by the start of their code, before falling back to its letter.
+var prefixCategories = []struct {
+	prefix   string
+	category string
+}{
+	// D04.x are Time of Day events, though D is otherwise DHCP.
+	{"D04.", EventCategoryToD},
+}
+
+// letterCategories categorise events which aren't in the catalogue
+// by the letter of their code.
+var letterCategories = map[byte]string{
+	'B': EventCategoryBPI,
+	'C': EventCategoryDCC,
+	'D': EventCategoryDHCP,
+	'E': EventCategorySoftwareDownload,
+	'I': EventCategoryRegistration,
+	'R': EventCategoryRanging,
+	'T': EventCategorySync,
+}
+
+// LookupEventCode describes a DOCSIS event ID. Event IDs are the
+// ASCII value of the code's letter followed by its number and
+// sub-number, e.g. 82000200 is R02.0, so every well-formed ID has
+// a code and category, even if it isn't in the catalogue.
+func LookupEventCode(eventID int) (EventCode, bool) {
+	letter := eventID / 1000000
+	if letter < 'A' || letter > 'Z' {
+		return EventCode{}, false
+	}
+
+	eventCode, ok := eventCatalogue[eventID]
+	eventCode.Code = fmt.Sprintf("%c%02d.%d", letter, eventID/100%10000, eventID%100)
+	if !ok {
+		eventCode.Category = uncataloguedCategory(eventCode.Code)
+	}
+
+	return eventCode, true
+}
+
+func uncataloguedCategory(code string) string {
+	for _, prefixCategory := range prefixCategories {
+		if strings.HasPrefix(code, prefixCategory.prefix) {
+			return prefixCategory.category
+		}
+	}
+	if category, ok := letterCategories[code[0]]; ok {
+		return category
+	}
+
+	return EventCategoryOther
+}
+
+// severityNames are the names of the DOCSIS event priorities.
+var severityNames = map[int]string{
+	1: "emergency",
+	2: "alert",
+	3: "critical",
+	4: "error",
+	5: "warning",
+	6: "notice",
+	7: "information",
+	8: "debug",
+}
+
+// SeverityName returns the name of a DOCSIS event level, e.g.
+// "critical" for 3, or an empty string if it is unknown.
+func SeverityName(eventLevel int) string {
+	return severityNames[eventLevel]
+}
+
+// EventDetails is an event log description split into its parts.
+type EventDetails struct {
+	Message       string `json:",omitempty"`
+	CMMAC         string `json:",omitempty"`
+	CMTSMAC       string `json:",omitempty"`
+	QoS           string `json:",omitempty"`
+	DOCSISVersion string `json:",omitempty"`
+}
+
+// ParseEventDescription splits a description such as
+// "No Ranging Response received - T3 time-out;CM-MAC=...;CMTS-MAC=...;CM-QOS=1.1;CM-VER=3.0;"
+// into the message and the identifiers the modem appends to it.
+func ParseEventDescription(description string) EventDetails {
+	details := EventDetails{}
+	identifiers := map[string]*string{
+		"CM-MAC":   &details.CMMAC,
+		"CMTS-MAC": &details.CMTSMAC,
+		"CM-QOS":   &details.QoS,
+		"CM-VER":   &details.DOCSISVersion,
+	}
+
+	// The message itself may contain ";", so identifiers are taken
+	// from the end for as long as they are recognised.
+	parts := strings.Split(description, ";")
+	end := len(parts)
+	for ; end > 0; end-- {
+		part := strings.TrimSpace(parts[end-1])
+		if part == "" {
+			continue
+		}
+
+		keyValue := strings.SplitN(part, "=", 2)
+		field, ok := identifiers[keyValue[0]]
+		if !ok || len(keyValue) != 2 {
+			break
+		}
+		*field = keyValue[1]
+	}
+	details.Message = strings.TrimSpace(strings.Join(parts[:end], ";"))
+
+	return details
+}
@@ -0,0 +1,104 @@
+package scrape
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupEventCode(t *testing.T) {
+	tests := []struct {
+		eventID  int
+		code     string
+		category string
+		named    bool
+	}{
+		{82000200, "R02.0", EventCategoryT3Timeout, true},
+		{82000400, "R04.0", EventCategoryT4Timeout, true},
+		{68000100, "D01.0", EventCategoryDHCP, true},
+		{68000200, "D02.0", EventCategoryDHCP, true},
+		{68010100, "D101.0", EventCategoryDHCP, true},
+		{68010200, "D102.0", EventCategoryDHCP, true},
+		{68010300, "D103.0", EventCategoryDHCP, true},
+		{68000401, "D04.1", EventCategoryToD, true},
+		{68000402, "D04.2", EventCategoryToD, true},
+		{84000200, "T02.0", EventCategorySync, true},
+		// Not in the catalogue, so categorised by letter.
+		{68000407, "D04.7", EventCategoryToD, false},
+		{68000300, "D03.0", EventCategoryDHCP, false},
+		{74010100, "J101.0", EventCategoryOther, false},
+	}
+
+	for _, tt := range tests {
+		eventCode, ok := LookupEventCode(tt.eventID)
+		assert.True(t, ok, tt.eventID)
+		assert.Equal(t, tt.code, eventCode.Code, tt.eventID)
+		assert.Equal(t, tt.category, eventCode.Category, tt.eventID)
+		assert.Equal(t, tt.named, eventCode.Name != "", tt.eventID)
+	}
+
+	_, ok := LookupEventCode(0)
+	assert.False(t, ok)
+	_, ok = LookupEventCode(1000)
+	assert.False(t, ok)
+}
+
+func TestSeverityName(t *testing.T) {
+	assert.Equal(t, "critical", SeverityName(3))
+	assert.Equal(t, "notice", SeverityName(6))
+	assert.Equal(t, "", SeverityName(0))
+}
+
+func TestParseEventDescription(t *testing.T) {
+	tests := []struct {
+		description string
+		expected    EventDetails
+	}{
+		{
+			"SYNC Timing Synchronization failure - Failed to acquire FEC framing;CM-MAC=th:is:is:fa:ke:00;CMTS-MAC=00:01:5c:00:00:01;CM-QOS=1.1;CM-VER=3.1;",
+			EventDetails{
+				Message:       "SYNC Timing Synchronization failure - Failed to acquire FEC framing",
+				CMMAC:         "th:is:is:fa:ke:00",
+				CMTSMAC:       "00:01:5c:00:00:01",
+				QoS:           "1.1",
+				DOCSISVersion: "3.1",
+			},
+		},
+		{
+			"CM-STATUS message sent. Event Type Code: 16; Chan ID: 32; DSID: N/A.;CM-MAC=th:is:is:fa:ke:02;CM-VER=3.1;",
+			EventDetails{
+				Message:       "CM-STATUS message sent. Event Type Code: 16; Chan ID: 32; DSID: N/A.",
+				CMMAC:         "th:is:is:fa:ke:02",
+				DOCSISVersion: "3.1",
+			},
+		},
+		{
+			"Honoring MDD; IP provisioning mode = IPv6",
+			EventDetails{Message: "Honoring MDD; IP provisioning mode = IPv6"},
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, ParseEventDescription(tt.description))
+	}
+}
+
+func TestEventLogDecode(t *testing.T) {
+	eventLog := EventLog{
+		EventID:     82000200,
+		EventLevel:  3,
+		Description: "No Ranging Response received - T3 time-out;CM-MAC=th:is:is:fa:ke:00;CMTS-MAC=00:01:5c:00:00:01;CM-QOS=1.1;CM-VER=3.0;",
+	}.Decode()
+
+	jsonBytes, err := json.Marshal(eventLog)
+	assert.NoError(t, err)
+	var decoded map[string]interface{}
+	assert.NoError(t, json.Unmarshal(jsonBytes, &decoded))
+	assert.Equal(t, "No Ranging Response received - T3 time-out", decoded["Message"])
+	assert.Equal(t, "00:01:5c:00:00:01", decoded["CMTSMAC"])
+	assert.Equal(t, "3.0", decoded["DOCSISVersion"])
+	assert.Equal(t, "R02.0", decoded["Code"])
+	assert.Equal(t, EventCategoryT3Timeout, decoded["Category"])
+	assert.Equal(t, "critical", decoded["Severity"])
+}
@@ -2,6 +2,8 @@ package scrape
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
@@ -11,30 +13,81 @@ import (
 )
 
 const (
-	dateTimeLayout = "01/02/2006 15:04 MST"
+	dateTimeLayout = "01/02/2006 15:04"
+
+	// timeNotEstablished is shown in place of the time of events
+	// logged before the modem's clock was set.
+	timeNotEstablished = "Time Not Established"
 )
 
 // EventLog holds data pulled from the /cmeventlog.html page.
 type EventLog struct {
-	DateTime    string
+	// DateTime is when the event was logged, in the modem's
+	// timezone, or the zero time if the modem's clock wasn't set.
+	DateTime time.Time
+	// RawDateTime is the time as shown by the modem.
+	RawDateTime string
 	EventID     int
 	EventLevel  int
 	Description string
+
+	// The rest is decoded from the above by Decode.
+	EventDetails
+	// Code and Category describe EventID, see LookupEventCode.
+	Code     string `json:",omitempty"`
+	Category string `json:",omitempty"`
+	// Severity is the name of EventLevel, e.g. "critical".
+	Severity string `json:",omitempty"`
+}
+
+// Decode returns e with the parts of its description, and the
+// code, category and severity of the event, filled in.
+func (e EventLog) Decode() EventLog {
+	e.EventDetails = ParseEventDescription(e.Description)
+	if eventCode, ok := LookupEventCode(e.EventID); ok {
+		e.Code = eventCode.Code
+		e.Category = eventCode.Category
+	}
+	e.Severity = SeverityName(e.EventLevel)
+
+	return e
 }
 
 // ToInfluxPoints converts EventLog to "points"
-func (e EventLog) ToInfluxPoints() ([]*client.Point, error) {
+func (e EventLog) ToInfluxPoints(options InfluxOptions) ([]*client.Point, error) {
+	timestamp := options.Time
+	if options.eventTimestamps() {
+		timestamp = e.eventTime(options.Time)
+	}
+
+	return e.toInfluxPoints(options, timestamp)
+}
+
+func (e EventLog) toInfluxPoints(options InfluxOptions, timestamp time.Time) ([]*client.Point, error) {
 	var points []*client.Point
 
-	// No tags for this specific struct.
 	tags := map[string]string{}
 	fields := map[string]interface{}{
-		"date_time":   e.DateTime,
-		"event_id":    e.EventID,
-		"event_level": e.EventLevel,
+		"date_time":   e.DateTimeString(),
 		"description": e.Description,
 	}
-	point, err := client.NewPoint("event_log", tags, fields, time.Now())
+	if options.Schema == InfluxSchemaV2 {
+		tags["event_id"] = strconv.Itoa(e.EventID)
+		tags["event_level"] = strconv.Itoa(e.EventLevel)
+		// Models without event IDs have no code or category.
+		for key, value := range map[string]string{"code": e.Code, "category": e.Category, "severity": e.Severity} {
+			if value != "" {
+				tags[key] = value
+			}
+		}
+		if e.Message != "" {
+			fields["message"] = e.Message
+		}
+	} else {
+		fields["event_id"] = e.EventID
+		fields["event_level"] = e.EventLevel
+	}
+	point, err := client.NewPoint("event_log", tags, fields, timestamp)
 	if err != nil {
 		return nil, fmt.Errorf("error generating points data for EventLog: %s", err.Error())
 	}
@@ -46,7 +99,7 @@ func (e EventLog) ToInfluxPoints() ([]*client.Point, error) {
 
 const eventLogTableSelector = "#bg3 > div.container > div.content > form > center > table"
 
-func scrapeEventLogs(logger *zap.Logger, doc *goquery.Document) []EventLog {
+func scrapeEventLogs(logger *zap.Logger, doc *goquery.Document, location *time.Location) []EventLog {
 	eventLogTable := doc.Find(eventLogTableSelector)
 	eventLogTableTbody := eventLogTable.Children()
 	eventLogTableTbodyRows := eventLogTableTbody.Children()
@@ -56,46 +109,89 @@ func scrapeEventLogs(logger *zap.Logger, doc *goquery.Document) []EventLog {
 		// Skip the "title" row as well as the "header" row.
 		// These are both regular old <tr> rows on this page.
 		if index > 0 {
-			eventLogs = append(eventLogs, makeEventLog(logger, row))
+			eventLogs = append(eventLogs, makeEventLog(logger, row, location))
 		}
 	})
 
 	return eventLogs
 }
 
-func makeEventLog(logger *zap.Logger, selection *goquery.Selection) EventLog {
+func makeEventLog(logger *zap.Logger, selection *goquery.Selection, location *time.Location) EventLog {
 	rowData := selection.Children()
+	rawDateTime := rowData.Get(0).FirstChild.Data
 	eventLog := EventLog{
-		DateTime:    rowData.Get(0).FirstChild.Data,
+		DateTime:    parseEventTime(logger, dateTimeLayout, rawDateTime, location),
+		RawDateTime: rawDateTime,
 		EventID:     getIntRowData(rowData, 1),
 		EventLevel:  getIntRowData(rowData, 2),
 		Description: rowData.Get(3).FirstChild.Data,
 	}
 
-	eventLog.DateTime = formatTime(logger, eventLog.DateTime)
-
 	return eventLog
 }
 
-func formatTime(logger *zap.Logger, datetime string) string {
-	now := time.Now()
-	zone, _ := now.Zone()
-	t, err := time.Parse(dateTimeLayout, datetime+" "+zone)
+// parseEventTime parses an event log time in the given layout and
+// the modem's location, returning the zero time if the modem's
+// clock wasn't set or the time can't be parsed.
+func parseEventTime(logger *zap.Logger, layout string, datetime string, location *time.Location) time.Time {
+	datetime = strings.Join(strings.Fields(datetime), " ")
+	if datetime == timeNotEstablished {
+		return time.Time{}
+	}
+
+	t, err := time.ParseInLocation(layout, datetime, location)
 	if err != nil {
+		observer.ObserveParseError(SectionEventLog)
 		logger.Error("failed to parse time",
-			zap.String("op", "scrape.formatTime"),
+			zap.String("op", "scrape.parseEventTime"),
 			zap.Error(err),
 		)
-		return datetime
+		return time.Time{}
+	}
+	return t
+}
+
+// DateTimeString returns DateTime in RFC3339, or RawDateTime if
+// the modem's clock wasn't set.
+func (e EventLog) DateTimeString() string {
+	if e.DateTime.IsZero() {
+		return e.RawDateTime
 	}
-	return t.Format(time.RFC3339)
+	return e.DateTime.Format(time.RFC3339)
 }
 
-func buildEventLogPoints(logs []EventLog) ([]*client.Point, error) {
+// eventTime is when the event was logged, or fallback if the
+// modem's clock wasn't set at the time.
+func (e EventLog) eventTime(fallback time.Time) time.Time {
+	if e.DateTime.IsZero() {
+		return fallback
+	}
+	return e.DateTime
+}
+
+// buildEventLogPoints converts logs to "points", timestamped with
+// when each event was logged if options ask for it. Events logged
+// before the modem's clock was set are timestamped with bootTime.
+func buildEventLogPoints(logs []EventLog, options InfluxOptions, bootTime time.Time) ([]*client.Point, error) {
 	var points []*client.Point
 
+	// Points in a series with the same timestamp overwrite each
+	// other, and events share one: the poll's, or the minute the
+	// modem logged them in. So events sharing a timestamp are
+	// spaced a nanosecond apart. Their order in the log is kept,
+	// so each poll writes the same points again.
+	used := map[int64]bool{}
 	for _, log := range logs {
-		influxPoints, err := log.ToInfluxPoints()
+		timestamp := options.Time
+		if options.eventTimestamps() {
+			timestamp = log.eventTime(bootTime)
+		}
+		for used[timestamp.UnixNano()] {
+			timestamp = timestamp.Add(time.Nanosecond)
+		}
+		used[timestamp.UnixNano()] = true
+
+		influxPoints, err := log.toInfluxPoints(options, timestamp)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,31 @@
+package scrape
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestParseEventTime(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	// The offset follows the date, not the time of the poll.
+	winter := parseEventTime(zap.NewNop(), dateTimeLayout, "01/10/2019 03:30", newYork)
+	assert.Equal(t, "2019-01-10T03:30:00-05:00", winter.Format(time.RFC3339))
+	summer := parseEventTime(zap.NewNop(), dateTimeLayout, "07/10/2019 03:30", newYork)
+	assert.Equal(t, "2019-07-10T03:30:00-04:00", summer.Format(time.RFC3339))
+
+	assert.True(t, parseEventTime(zap.NewNop(), dateTimeLayout, " Time  Not Established ", newYork).IsZero())
+	assert.True(t, parseEventTime(zap.NewNop(), dateTimeLayout, "yesterday", newYork).IsZero())
+}
+
+func TestEventLogDateTimeString(t *testing.T) {
+	eventLog := EventLog{RawDateTime: "Time Not Established"}
+	assert.Equal(t, "Time Not Established", eventLog.DateTimeString())
+
+	eventLog.DateTime = time.Date(2019, 10, 10, 21, 43, 0, 0, time.UTC)
+	assert.Equal(t, "2019-10-10T21:43:00Z", eventLog.DateTimeString())
+}
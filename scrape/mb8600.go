@@ -0,0 +1,429 @@
+package scrape
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/janse180/modem-scraper/config"
+	"go.uber.org/zap"
+)
+
+const (
+	hnapNamespace       = "http://purenetworks.com/HNAP1/"
+	hnapWithoutLoginKey = "withoutloginkey"
+	hnapRowSeparator    = "|+|"
+	hnapLogSeparator    = "}-{"
+	hnapFieldSeparator  = "^"
+	mb8600LogLayout     = "15:04:05 Mon Jan 2 2006"
+)
+
+func init() {
+	RegisterDriver("mb8600", newMB8600Driver)
+}
+
+// mb8600Driver scrapes the Motorola MB8600 through its HNAP API,
+// which wraps SOAP actions in JSON and signs every request with
+// an HMAC of the action and a timestamp.
+type mb8600Driver struct {
+	logger     *zap.Logger
+	conf       config.Modem
+	client     *http.Client
+	location   *time.Location
+	uid        string
+	privateKey string
+}
+
+func newMB8600Driver(logger *zap.Logger, conf config.Modem, client *http.Client, location *time.Location) Driver {
+	return &mb8600Driver{
+		logger:   logger,
+		conf:     conf,
+		client:   client,
+		location: location,
+	}
+}
+
+type hnapLoginResponse struct {
+	LoginResponse struct {
+		Challenge   string
+		Cookie      string
+		PublicKey   string
+		LoginResult string
+	}
+}
+
+// Login performs the HNAP challenge/response login, deriving the
+// private key which signs all subsequent requests.
+func (d *mb8600Driver) Login(ctx context.Context) error {
+	d.uid = ""
+	d.privateKey = ""
+
+	var challenge hnapLoginResponse
+	err := d.hnapRequest(ctx, "Login", map[string]interface{}{
+		"Login": map[string]string{
+			"Action":        "request",
+			"Username":      d.conf.Username,
+			"LoginPassword": "",
+			"Captcha":       "",
+			"PrivateLogin":  "LoginPassword",
+		},
+	}, &challenge)
+	if err != nil {
+		return err
+	}
+
+	privateKey := hnapHMAC(challenge.LoginResponse.PublicKey+d.conf.Password, challenge.LoginResponse.Challenge)
+	d.uid = challenge.LoginResponse.Cookie
+	d.privateKey = privateKey
+
+	var login hnapLoginResponse
+	err = d.hnapRequest(ctx, "Login", map[string]interface{}{
+		"Login": map[string]string{
+			"Action":        "login",
+			"Username":      d.conf.Username,
+			"LoginPassword": hnapHMAC(privateKey, challenge.LoginResponse.Challenge),
+			"Captcha":       "",
+			"PrivateLogin":  "LoginPassword",
+		},
+	}, &login)
+	if err != nil {
+		return err
+	}
+	if login.LoginResponse.LoginResult != "OK" {
+		return fmt.Errorf("login failed: %s", login.LoginResponse.LoginResult)
+	}
+
+	return nil
+}
+
+// FetchConnectionStatus retrieves the startup sequence and
+// channel information.
+func (d *mb8600Driver) FetchConnectionStatus(ctx context.Context) (*ConnectionStatus, error) {
+	responses, err := d.getMultipleHNAPs(ctx,
+		"GetMotoStatusStartupSequence",
+		"GetMotoStatusConnectionInfo",
+		"GetMotoStatusDownstreamChannelInfo",
+		"GetMotoStatusUpstreamChannelInfo",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseMB8600ConnectionStatus(responses), nil
+}
+
+// FetchSoftwareInformation retrieves the versions and uptime.
+func (d *mb8600Driver) FetchSoftwareInformation(ctx context.Context) (*SoftwareInformation, error) {
+	responses, err := d.getMultipleHNAPs(ctx,
+		"GetMotoStatusSoftware",
+		"GetMotoStatusConnectionInfo",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseMB8600SoftwareInformation(responses), nil
+}
+
+// FetchEventLog retrieves the event log.
+func (d *mb8600Driver) FetchEventLog(ctx context.Context) ([]EventLog, error) {
+	responses, err := d.getMultipleHNAPs(ctx, "GetMotoStatusLog")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseMB8600EventLogs(d.logger, responses, d.location), nil
+}
+
+// Logout ends the HNAP session.
+func (d *mb8600Driver) Logout(ctx context.Context) error {
+	err := d.hnapRequest(ctx, "Logout", map[string]interface{}{
+		"Logout": map[string]string{},
+	}, nil)
+	d.uid = ""
+	d.privateKey = ""
+
+	return err
+}
+
+// hnapResponses maps each "<Action>Response" key to its
+// field/value pairs.
+type hnapResponses map[string]map[string]string
+
+func (r hnapResponses) value(action string, field string) string {
+	return strings.TrimSpace(r[action+"Response"][field])
+}
+
+func (d *mb8600Driver) getMultipleHNAPs(ctx context.Context, actions ...string) (hnapResponses, error) {
+	request := map[string]string{}
+	for _, action := range actions {
+		request[action] = ""
+	}
+
+	var response struct {
+		GetMultipleHNAPsResponse map[string]json.RawMessage
+	}
+	err := d.hnapRequest(ctx, "GetMultipleHNAPs", map[string]interface{}{
+		"GetMultipleHNAPs": request,
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each action's response is an object of strings, alongside
+	// the plain string "GetMultipleHNAPsResult".
+	responses := hnapResponses{}
+	for key, raw := range response.GetMultipleHNAPsResponse {
+		fields := map[string]string{}
+		if json.Unmarshal(raw, &fields) == nil {
+			responses[key] = fields
+		}
+	}
+	switch result := string(response.GetMultipleHNAPsResponse["GetMultipleHNAPsResult"]); result {
+	case `"OK"`:
+	case `"UN-AUTH"`:
+		return nil, ErrSessionExpired
+	default:
+		return nil, fmt.Errorf("HNAP request failed: %s", result)
+	}
+
+	return responses, nil
+}
+
+func (d *mb8600Driver) hnapRequest(ctx context.Context, action string, body interface{}, out interface{}) (err error) {
+	address := d.conf.Url + "/HNAP1/"
+	d.logger.Debug(fmt.Sprintf("calling %s on %s", action, address),
+		zap.String("op", "scrape.hnapRequest"),
+	)
+
+	start := time.Now()
+	defer func() { observePageFetch(address+action, start, err) }()
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", address, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	soapAction := `"` + hnapNamespace + action + `"`
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("SOAPAction", soapAction)
+	req.Header.Set("HNAP_AUTH", d.hnapAuth(soapAction, time.Now()))
+	if d.uid != "" {
+		req.AddCookie(&http.Cookie{Name: "uid", Value: d.uid})
+		req.AddCookie(&http.Cookie{Name: "PrivateKey", Value: d.privateKey})
+	}
+
+	resp, err := d.client.Do(req)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && d.uid != "" {
+		return ErrSessionExpired
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+	}
+
+	if out != nil {
+		err = json.NewDecoder(resp.Body).Decode(out)
+		if err != nil {
+			return fmt.Errorf("error decoding %s response: %s", action, err.Error())
+		}
+	}
+
+	elapsed := time.Since(start)
+	d.logger.Debug(fmt.Sprintf("called %s, took %s", action, elapsed),
+		zap.String("op", "scrape.hnapRequest"),
+	)
+
+	return nil
+}
+
+// hnapAuth builds the HNAP_AUTH header, "<HMAC> <timestamp>", where
+// the HMAC is keyed with the private key once logged in.
+func (d *mb8600Driver) hnapAuth(soapAction string, now time.Time) string {
+	key := d.privateKey
+	if key == "" {
+		key = hnapWithoutLoginKey
+	}
+	timestamp := strconv.FormatInt((now.UnixNano()/int64(time.Millisecond))%2000000000000, 10)
+
+	return hnapHMAC(key, timestamp+soapAction) + " " + timestamp
+}
+
+// hnapHMAC returns the upper case hex HMAC-MD5 of message.
+func hnapHMAC(key string, message string) string {
+	mac := hmac.New(md5.New, []byte(key))
+	mac.Write([]byte(message))
+
+	return strings.ToUpper(hex.EncodeToString(mac.Sum(nil)))
+}
+
+func parseMB8600ConnectionStatus(responses hnapResponses) *ConnectionStatus {
+	connectionStatus := ConnectionStatus{
+		StartupProcedure:         parseMB8600StartupProcedure(responses),
+		DownstreamBondedChannels: parseMB8600DownstreamBondedChannels(responses),
+		UpstreamBondedChannels:   parseMB8600UpstreamBondedChannels(responses),
+	}
+
+	return &connectionStatus
+}
+
+func parseMB8600StartupProcedure(responses hnapResponses) StartupProcedure {
+	value := func(field string) string {
+		return responses.value("GetMotoStatusStartupSequence", field)
+	}
+
+	startupProcedure := StartupProcedure{
+		AcquireDownstreamChannel: Status{
+			Status:  value("MotoConnDSFreq"),
+			Comment: value("MotoConnDSComment"),
+		},
+		ConnectivityState: Status{
+			Status:  value("MotoConnConnectivityStatus"),
+			Comment: value("MotoConnConnectivityComment"),
+		},
+		BootState: Status{
+			Status:  value("MotoConnBootStatus"),
+			Comment: value("MotoConnBootComment"),
+		},
+		ConfigurationFile: Status{
+			Status:  value("MotoConnConfigurationFileStatus"),
+			Comment: value("MotoConnConfigurationFileComment"),
+		},
+		Security: Status{
+			Status:  value("MotoConnSecurityStatus"),
+			Comment: value("MotoConnSecurityComment"),
+		},
+		DOCSISNetworkAccessEnabled: Status{
+			Status: responses.value("GetMotoStatusConnectionInfo", "MotoConnNetworkAccess"),
+		},
+	}
+
+	return startupProcedure
+}
+
+// "1^Locked^QAM256^20^567.0^ 3.5^41.2^0^0^|+|2^..."
+func parseMB8600DownstreamBondedChannels(responses hnapResponses) []DownstreamBondedChannel {
+	rows := hnapRows(responses.value("GetMotoStatusDownstreamChannelInfo", "MotoConnDownstreamChannel"), hnapRowSeparator)
+
+	downstreamBondedChannels := []DownstreamBondedChannel{}
+	for _, row := range rows {
+		if len(row) < 9 {
+			continue
+		}
+		downstreamBondedChannels = append(downstreamBondedChannels, DownstreamBondedChannel{
+			ChannelID:      atoiField(row[3]),
+			LockStatus:     row[1],
+			Modulation:     row[2],
+			FrequencyHz:    megahertzToHz(row[4]),
+			PowerdBmV:      atofField(row[5]),
+			SNRdB:          atofField(row[6]),
+			Corrected:      atoiField(row[7]),
+			Uncorrectables: atoiField(row[8]),
+		})
+	}
+
+	return downstreamBondedChannels
+}
+
+// "1^Locked^SC-QAM^1^5120^16.4^41.5^|+|2^..."
+func parseMB8600UpstreamBondedChannels(responses hnapResponses) []UpstreamBondedChannel {
+	rows := hnapRows(responses.value("GetMotoStatusUpstreamChannelInfo", "MotoConnUpstreamChannel"), hnapRowSeparator)
+
+	upstreamBondedChannels := []UpstreamBondedChannel{}
+	for _, row := range rows {
+		if len(row) < 7 {
+			continue
+		}
+		upstreamBondedChannels = append(upstreamBondedChannels, UpstreamBondedChannel{
+			Channel:       atoiField(row[0]),
+			ChannelID:     atoiField(row[3]),
+			LockStatus:    row[1],
+			USChannelType: row[2],
+			FrequencyHz:   megahertzToHz(row[5]),
+			WidthHz:       symbolRateToWidthHz(atoiField(row[4])),
+			PowerdBmV:     atofField(row[6]),
+		})
+	}
+
+	return upstreamBondedChannels
+}
+
+func parseMB8600SoftwareInformation(responses hnapResponses) *SoftwareInformation {
+	value := func(field string) string {
+		return responses.value("GetMotoStatusSoftware", field)
+	}
+
+	uptimeString := responses.value("GetMotoStatusConnectionInfo", "MotoConnSystemUpTime")
+	softwareInformation := SoftwareInformation{
+		StandardSpecificationCompliant: value("StatusSoftwareSpecVer"),
+		HardwareVersion:                value("StatusSoftwareHdVer"),
+		SoftwareVersion:                value("StatusSoftwareSfVer"),
+		MACAddress:                     value("StatusSoftwareMac"),
+		SerialNumber:                   value("StatusSoftwareSerialNum"),
+		UptimeMins:                     parseUptimeMinutes(uptimeString),
+		UptimeString:                   uptimeString,
+	}
+
+	return &softwareInformation
+}
+
+// "09:41:20^Thu Oct 10 2019^Critical (3)^No Ranging Response...}-{..."
+func parseMB8600EventLogs(logger *zap.Logger, responses hnapResponses, location *time.Location) []EventLog {
+	rows := hnapRows(responses.value("GetMotoStatusLog", "MotoStatusLogList"), hnapLogSeparator)
+
+	eventLogs := []EventLog{}
+	for _, row := range rows {
+		if len(row) < 4 {
+			continue
+		}
+		rawDateTime := strings.Join(strings.Fields(row[0]+" "+row[1]), " ")
+		eventLogs = append(eventLogs, EventLog{
+			DateTime:    parseEventTime(logger, mb8600LogLayout, rawDateTime, location),
+			RawDateTime: rawDateTime,
+			EventLevel:  priorityToEventLevel(row[2]),
+			Description: row[3],
+		})
+	}
+
+	return eventLogs
+}
+
+// hnapRows splits an HNAP table into rows of trimmed fields.
+func hnapRows(table string, rowSeparator string) [][]string {
+	rows := [][]string{}
+	for _, row := range strings.Split(table, rowSeparator) {
+		if strings.TrimSpace(row) == "" {
+			continue
+		}
+		fields := strings.Split(row, hnapFieldSeparator)
+		for i, field := range fields {
+			fields[i] = strings.TrimSpace(field)
+		}
+		rows = append(rows, fields)
+	}
+
+	return rows
+}
+
+func megahertzToHz(data string) int {
+	return int(math.Round(atofField(data) * 1000000))
+}
@@ -0,0 +1,175 @@
+package scrape
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/janse180/modem-scraper/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const (
+	testHNAPChallenge = "ABCDEFGHIJ0123456789"
+	testHNAPPublicKey = "KLMNOPQRST9876543210"
+	testHNAPCookie    = "fakeuid123"
+)
+
+// newMB8600TestServer emulates the HNAP login handshake and answers
+// GetMultipleHNAPs from the fixtures in testdata/mb8600, rejecting
+// any request that is not signed with the expected key.
+func newMB8600TestServer(t *testing.T, password string) *httptest.Server {
+	privateKey := hnapHMAC(testHNAPPublicKey+password, testHNAPChallenge)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		action := strings.TrimSuffix(strings.TrimPrefix(r.Header.Get("SOAPAction"), `"`+hnapNamespace), `"`)
+
+		key := hnapWithoutLoginKey
+		if cookie, err := r.Cookie("uid"); err == nil && cookie.Value == testHNAPCookie {
+			key = privateKey
+		}
+		auth := strings.Split(r.Header.Get("HNAP_AUTH"), " ")
+		if len(auth) != 2 || auth[0] != hnapHMAC(key, auth[1]+r.Header.Get("SOAPAction")) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var request map[string]map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		response := map[string]interface{}{}
+		switch action {
+		case "Login":
+			login := request["Login"]
+			result := "OK"
+			if login["Action"] == "login" && login["LoginPassword"] != hnapHMAC(privateKey, testHNAPChallenge) {
+				result = "FAILED"
+			}
+			response["LoginResponse"] = map[string]string{
+				"Challenge":   testHNAPChallenge,
+				"Cookie":      testHNAPCookie,
+				"PublicKey":   testHNAPPublicKey,
+				"LoginResult": result,
+			}
+		case "GetMultipleHNAPs":
+			if key != privateKey {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			responses := map[string]interface{}{"GetMultipleHNAPsResult": "OK"}
+			for subAction := range request["GetMultipleHNAPs"] {
+				fixture, err := ioutil.ReadFile("../testdata/mb8600/" + subAction + ".json")
+				if err != nil {
+					// The handler isn't on the test goroutine, so the
+					// test fails on the scrape error instead.
+					t.Errorf("unable to open fixture for %s", subAction)
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				responses[subAction+"Response"] = json.RawMessage(fixture)
+			}
+			response["GetMultipleHNAPsResponse"] = responses
+		case "Logout":
+			response["LogoutResponse"] = map[string]string{"LogoutResult": "OK"}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(response)
+	}))
+}
+
+func TestMB8600Driver(t *testing.T) {
+	server := newMB8600TestServer(t, "motorola")
+	defer server.Close()
+
+	driver, err := NewDriver(zap.NewNop(), config.Modem{
+		Model:    "mb8600",
+		Url:      server.URL,
+		Username: "admin",
+		Password: "motorola",
+		Timezone: "America/Chicago",
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, driver.Login(context.Background()))
+
+	connectionStatus, err := driver.FetchConnectionStatus(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, StartupProcedure{
+		AcquireDownstreamChannel:   Status{Status: "567000000 Hz", Comment: "Locked"},
+		ConnectivityState:          Status{Status: "OK", Comment: "Operational"},
+		BootState:                  Status{Status: "OK", Comment: "Operational"},
+		ConfigurationFile:          Status{Status: "OK", Comment: ""},
+		Security:                   Status{Status: "Enabled", Comment: "BPI+"},
+		DOCSISNetworkAccessEnabled: Status{Status: "Allowed"},
+	}, connectionStatus.StartupProcedure)
+	assert.Len(t, connectionStatus.DownstreamBondedChannels, 32)
+	assert.Equal(t, DownstreamBondedChannel{
+		ChannelID:      20,
+		LockStatus:     "Locked",
+		Modulation:     "QAM256",
+		FrequencyHz:    567000000,
+		PowerdBmV:      3.5,
+		SNRdB:          41.2,
+		Corrected:      0,
+		Uncorrectables: 0,
+	}, connectionStatus.DownstreamBondedChannels[0])
+	assert.Len(t, connectionStatus.UpstreamBondedChannels, 4)
+	assert.Equal(t, UpstreamBondedChannel{
+		Channel:       1,
+		ChannelID:     1,
+		LockStatus:    "Locked",
+		USChannelType: "SC-QAM",
+		FrequencyHz:   16400000,
+		WidthHz:       6400000,
+		PowerdBmV:     41.5,
+	}, connectionStatus.UpstreamBondedChannels[0])
+
+	softwareInformation, err := driver.FetchSoftwareInformation(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, &SoftwareInformation{
+		StandardSpecificationCompliant: "DOCSIS 3.1",
+		HardwareVersion:                "V1.0",
+		SoftwareVersion:                "8600-19.3.18",
+		MACAddress:                     "TH:IS:IS:FA:KE:03",
+		SerialNumber:                   "THISISFAKE8600",
+		UptimeMins:                     314,
+		UptimeString:                   "0 days 05h:14m:32s",
+	}, softwareInformation)
+
+	eventLogs, err := driver.FetchEventLog(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, eventLogs, 3)
+	assert.True(t, eventLogs[0].DateTime.IsZero())
+	assert.Equal(t, "Time Not Established", eventLogs[0].RawDateTime)
+	assert.Equal(t, "09:41:20 Thu Oct 10 2019", eventLogs[1].RawDateTime)
+	assert.Equal(t, "2019-10-10T09:41:20-05:00", eventLogs[1].DateTimeString())
+	assert.Equal(t, 3, eventLogs[0].EventLevel)
+	assert.Equal(t, 6, eventLogs[2].EventLevel)
+	assert.Equal(t, "Honoring MDD; IP provisioning mode = IPv6", eventLogs[2].Description)
+
+	assert.NoError(t, driver.Logout(context.Background()))
+}
+
+func TestMB8600DriverWithBadPasswordFailsLogin(t *testing.T) {
+	server := newMB8600TestServer(t, "motorola")
+	defer server.Close()
+
+	driver, err := NewDriver(zap.NewNop(), config.Modem{
+		Model:    "mb8600",
+		Url:      server.URL,
+		Username: "admin",
+		Password: "wrong",
+	})
+	assert.NoError(t, err)
+	assert.Error(t, driver.Login(context.Background()))
+}
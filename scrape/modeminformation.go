@@ -2,17 +2,84 @@ package scrape
 
 import (
 	"encoding/json"
+	"time"
 
 	_ "github.com/influxdata/influxdb1-client" // this is important because of a bug in go mod
 	client "github.com/influxdata/influxdb1-client/v2"
 )
 
 // ModemInformation holds all information from the
-// SB8200 status pages.
+// modem status pages.
 type ModemInformation struct {
 	ConnectionStatus    ConnectionStatus
 	SoftwareInformation SoftwareInformation
 	EventLog            []EventLog
+	// FailedSections lists the sections which could not be
+	// scraped, and are left empty, after a partial scrape.
+	FailedSections []string `json:",omitempty"`
+}
+
+// Scraped reports whether section was scraped, rather than
+// left empty after a partial scrape.
+func (m ModemInformation) Scraped(section string) bool {
+	for _, failed := range m.FailedSections {
+		if failed == section {
+			return false
+		}
+	}
+
+	return true
+}
+
+// InfluxDB point layouts, see InfluxOptions.
+const (
+	InfluxSchemaV1 = 1
+	InfluxSchemaV2 = 2
+)
+
+// Timestamps for event_log points, see InfluxOptions.
+const (
+	EventTimestampEvent = "event"
+	EventTimestampPoll  = "poll"
+)
+
+// InfluxOptions controls how ModemInformation is converted
+// to InfluxDB points.
+type InfluxOptions struct {
+	// Schema is InfluxSchemaV1 (the default), which stores nearly
+	// everything as fields, or InfluxSchemaV2, which stores
+	// categorical values as tags and adds numeric *_ok fields for
+	// statuses, so they can be grouped and alerted on.
+	Schema int
+	// Time is the timestamp of every point, defaulting to now.
+	Time time.Time
+	// EventTimestamp is EventTimestampEvent to timestamp event_log
+	// points with when the modem logged them, or EventTimestampPoll
+	// to use Time. It defaults to the poll time for InfluxSchemaV1,
+	// so existing dashboards keep working, and to the event time
+	// for InfluxSchemaV2.
+	EventTimestamp string
+	// BootTime is when the modem booted, which events logged before
+	// its clock was set are timestamped with. It defaults to an
+	// estimate from the uptime, which a BootClock keeps steady
+	// across polls.
+	BootTime time.Time
+}
+
+func (o InfluxOptions) eventTimestamps() bool {
+	if o.EventTimestamp == "" {
+		return o.Schema == InfluxSchemaV2
+	}
+	return o.EventTimestamp == EventTimestampEvent
+}
+
+// okField is the numeric value of an *_ok field, which InfluxQL
+// and Flux can average and alert on, unlike a boolean.
+func okField(ok bool) int {
+	if ok {
+		return 1
+	}
+	return 0
 }
 
 // ToJSON converts ModemInformation to JSON string.
@@ -38,26 +105,44 @@ func (m ModemInformation) ToJSON() (string, error) {
 // call each of the above to aggregate them all. Then this can
 // simply return []string with all the "lines" and they can
 // all be sent to InfluxDB at once.
-func (m ModemInformation) ToInfluxPoints() ([]*client.Point, error) {
+func (m ModemInformation) ToInfluxPoints(options InfluxOptions) ([]*client.Point, error) {
 	var points []*client.Point
 
-	influxPoints, err := m.ConnectionStatus.ToInfluxPoints()
-	if err != nil {
-		return nil, err
+	// Points from one scrape share a timestamp, other than event
+	// log entries, which buildEventLogPoints spaces apart.
+	if options.Time.IsZero() {
+		options.Time = time.Now()
 	}
-	points = append(points, influxPoints...)
 
-	influxPoints, err = m.SoftwareInformation.ToInfluxPoints()
-	if err != nil {
-		return nil, err
+	if m.Scraped(SectionConnectionStatus) {
+		influxPoints, err := m.ConnectionStatus.ToInfluxPoints(options)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, influxPoints...)
 	}
-	points = append(points, influxPoints...)
 
-	influxPoints, err = buildEventLogPoints(m.EventLog)
-	if err != nil {
-		return nil, err
+	if m.Scraped(SectionSoftwareInformation) {
+		influxPoints, err := m.SoftwareInformation.ToInfluxPoints(options)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, influxPoints...)
+	}
+
+	if m.Scraped(SectionEventLog) {
+		// Events logged before the modem's clock was set are placed
+		// at boot, which is when they happened, near enough.
+		bootTime := options.BootTime
+		if bootTime.IsZero() {
+			bootTime = estimateBootTime(options.Time, m)
+		}
+		influxPoints, err := buildEventLogPoints(m.EventLog, options, bootTime)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, influxPoints...)
 	}
-	points = append(points, influxPoints...)
 
 	return points, nil
 }
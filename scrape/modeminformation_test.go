@@ -0,0 +1,162 @@
+package scrape
+
+import (
+	"testing"
+	"time"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+var influxTestModemInformation = ModemInformation{
+	ConnectionStatus: ConnectionStatus{
+		StartupProcedure: StartupProcedure{
+			AcquireDownstreamChannel:   Status{Status: "519000000 Hz", Comment: "Locked"},
+			ConnectivityState:          Status{Status: "OK", Comment: "Operational"},
+			DOCSISNetworkAccessEnabled: Status{Status: "Denied"},
+		},
+		DownstreamBondedChannels: []DownstreamBondedChannel{
+			{ChannelID: 17, LockStatus: "Locked", Modulation: "QAM256", PowerdBmV: 5.3},
+		},
+		UpstreamBondedChannels: []UpstreamBondedChannel{
+			{Channel: 1, ChannelID: 2, LockStatus: "Not Locked", USChannelType: "ATDMA"},
+		},
+	},
+	SoftwareInformation: SoftwareInformation{SoftwareVersion: "1.0", UptimeMins: 60},
+	EventLog: []EventLog{
+		EventLog{RawDateTime: "Time Not Established", EventID: 82000200, EventLevel: 3, Description: "No Ranging Response received;CM-VER=3.0;"}.Decode(),
+	},
+}
+
+func TestToInfluxPointsSchemaV1(t *testing.T) {
+	points, err := influxTestModemInformation.ToInfluxPoints(InfluxOptions{})
+	assert.NoError(t, err)
+
+	downstream := influxPointNamed(t, points, "downstream_bonded_channel")
+	assert.Equal(t, map[string]string{"channel_id": "17"}, downstream.Tags())
+	fields, _ := downstream.Fields()
+	assert.Equal(t, "QAM256", fields["modulation"])
+	assert.NotContains(t, fields, "lock_status_ok")
+
+	eventLog := influxPointNamed(t, points, "event_log")
+	assert.Empty(t, eventLog.Tags())
+	fields, _ = eventLog.Fields()
+	assert.Equal(t, int64(3), fields["event_level"])
+}
+
+func TestToInfluxPointsSchemaV2(t *testing.T) {
+	timestamp := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	points, err := influxTestModemInformation.ToInfluxPoints(InfluxOptions{Schema: InfluxSchemaV2, Time: timestamp})
+	assert.NoError(t, err)
+
+	// Except for the event log, which is timestamped with when
+	// each event was logged.
+	for _, point := range points {
+		if point.Name() != "event_log" {
+			assert.Equal(t, timestamp, point.Time(), point.Name())
+		}
+	}
+
+	downstream := influxPointNamed(t, points, "downstream_bonded_channel")
+	assert.Equal(t, map[string]string{"channel_id": "17", "lock_status": "Locked", "modulation": "QAM256"}, downstream.Tags())
+	fields, _ := downstream.Fields()
+	assert.Equal(t, int64(1), fields["lock_status_ok"])
+	assert.NotContains(t, fields, "modulation")
+
+	upstream := influxPointNamed(t, points, "upstream_bonded_channel")
+	assert.Equal(t, "ATDMA", upstream.Tags()["us_channel_type"])
+	fields, _ = upstream.Fields()
+	assert.Equal(t, int64(0), fields["lock_status_ok"])
+
+	startupProcedure := influxPointNamed(t, points, "startup_procedure")
+	fields, _ = startupProcedure.Fields()
+	assert.Equal(t, int64(1), fields["acquire_downstream_channel_ok"])
+	assert.Equal(t, int64(1), fields["connectivity_state_ok"])
+	assert.Equal(t, int64(0), fields["docsis_network_access_enabled_ok"])
+
+	softwareInformation := influxPointNamed(t, points, "software_information")
+	assert.Equal(t, "1.0", softwareInformation.Tags()["software_version"])
+
+	eventLog := influxPointNamed(t, points, "event_log")
+	assert.Equal(t, map[string]string{
+		"event_id":    "82000200",
+		"event_level": "3",
+		"code":        "R02.0",
+		"category":    EventCategoryT3Timeout,
+		"severity":    "critical",
+	}, eventLog.Tags())
+	fields, _ = eventLog.Fields()
+	assert.Equal(t, "No Ranging Response received", fields["message"])
+}
+
+func TestToInfluxPointsSharesTimestamp(t *testing.T) {
+	points, err := influxTestModemInformation.ToInfluxPoints(InfluxOptions{})
+	assert.NoError(t, err)
+
+	for _, point := range points {
+		assert.Equal(t, points[0].Time(), point.Time(), point.Name())
+	}
+}
+
+func influxPointNamed(t *testing.T, points []*client.Point, name string) *client.Point {
+	for _, point := range points {
+		if point.Name() == name {
+			return point
+		}
+	}
+
+	t.Fatalf("no %s point", name)
+	return nil
+}
+
+func TestToInfluxPointsEventTimestamps(t *testing.T) {
+	eastern := time.FixedZone("EDT", -4*60*60)
+	now := time.Date(2019, 10, 11, 12, 0, 30, 0, time.UTC)
+	modemInformation := ModemInformation{
+		SoftwareInformation: SoftwareInformation{UptimeMins: 60},
+		EventLog: []EventLog{
+			{RawDateTime: "Time Not Established", EventID: 1},
+			{DateTime: time.Date(2019, 10, 10, 21, 43, 0, 0, eastern), EventID: 2},
+			{DateTime: time.Date(2019, 10, 10, 21, 43, 0, 0, eastern), EventID: 3},
+		},
+	}
+
+	points, err := modemInformation.ToInfluxPoints(InfluxOptions{Time: now, EventTimestamp: EventTimestampEvent})
+	assert.NoError(t, err)
+	eventLogs := []*client.Point{}
+	for _, point := range points {
+		if point.Name() == "event_log" {
+			eventLogs = append(eventLogs, point)
+		}
+	}
+	assert.Len(t, eventLogs, 3)
+
+	// Placed at boot, an hour before the poll.
+	assert.Equal(t, time.Date(2019, 10, 11, 11, 0, 0, 0, time.UTC), eventLogs[0].Time().UTC())
+	logged := time.Date(2019, 10, 11, 1, 43, 0, 0, time.UTC)
+	assert.Equal(t, logged, eventLogs[1].Time().UTC())
+	// Events in the same minute don't overwrite each other.
+	assert.Equal(t, logged.Add(time.Nanosecond), eventLogs[2].Time().UTC())
+
+	// The poll time is kept for the original schema by default, with
+	// each event spaced apart so that none of them is overwritten.
+	for _, options := range []InfluxOptions{
+		{Time: now},
+		{Schema: InfluxSchemaV2, Time: now, EventTimestamp: EventTimestampPoll},
+	} {
+		points, err = modemInformation.ToInfluxPoints(options)
+		assert.NoError(t, err)
+		eventLogs = []*client.Point{}
+		for _, point := range points {
+			if point.Name() == "event_log" {
+				eventLogs = append(eventLogs, point)
+			} else {
+				assert.Equal(t, now, point.Time().UTC(), point.Name())
+			}
+		}
+		assert.Len(t, eventLogs, 3)
+		for i, point := range eventLogs {
+			assert.Equal(t, now.Add(time.Duration(i)*time.Nanosecond), point.Time().UTC(), options.Schema)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package scrape
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/janse180/modem-scraper/config"
+	"go.uber.org/zap"
+)
+
+func init() {
+	RegisterDriver("cm1000", newNetgearDriver)
+	RegisterDriver("cm1200", newNetgearDriver)
+}
+
+// Field positions within the InitTagValue() list on /DocsisStatus.asp.
+const (
+	netgearAcquireDownstreamChannelStatus = iota
+	netgearAcquireDownstreamChannelComment
+	netgearConnectivityStateStatus
+	netgearConnectivityStateComment
+	netgearBootStateStatus
+	netgearBootStateComment
+	netgearConfigurationFileStatus
+	netgearConfigurationFileComment
+	netgearSecurityStatus
+	netgearSecurityComment
+	netgearDOCSISNetworkAccessEnabledStatus
+)
+
+// Field positions within the InitTagValue() list on /RouterStatus.asp.
+const (
+	netgearHardwareVersion = iota
+	netgearFirmwareVersion
+	netgearSerialNumber
+	netgearCableMACAddress
+	netgearDOCSISMode
+)
+
+// netgearDriver scrapes the Netgear CM1000 and CM1200. Rather than
+// HTML tables, these pages carry their data as "|" separated
+// strings returned by JavaScript Init*TagValue() functions, which
+// fill in the tables in the browser.
+type netgearDriver struct {
+	logger   *zap.Logger
+	conf     config.Modem
+	client   *http.Client
+	location *time.Location
+}
+
+func newNetgearDriver(logger *zap.Logger, conf config.Modem, client *http.Client, location *time.Location) Driver {
+	return &netgearDriver{
+		logger:   logger,
+		conf:     conf,
+		client:   client,
+		location: location,
+	}
+}
+
+// Login is a no-op, every page is requested with basic auth.
+func (d *netgearDriver) Login(ctx context.Context) error {
+	return nil
+}
+
+// FetchConnectionStatus scrapes /DocsisStatus.asp.
+func (d *netgearDriver) FetchConnectionStatus(ctx context.Context) (*ConnectionStatus, error) {
+	page, err := d.getPage(ctx, d.conf.Url+"/DocsisStatus.asp")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseNetgearConnectionStatus(page), nil
+}
+
+// FetchSoftwareInformation scrapes /RouterStatus.asp for the versions,
+// and /DocsisStatus.asp for the uptime. Within a Session's poll,
+// /DocsisStatus.asp is only fetched once for both sections.
+func (d *netgearDriver) FetchSoftwareInformation(ctx context.Context) (*SoftwareInformation, error) {
+	routerStatus, err := d.getPage(ctx, d.conf.Url+"/RouterStatus.asp")
+	if err != nil {
+		return nil, err
+	}
+
+	docsisStatus, err := d.getPage(ctx, d.conf.Url+"/DocsisStatus.asp")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseNetgearSoftwareInformation(routerStatus, docsisStatus), nil
+}
+
+// FetchEventLog scrapes /EventLog.asp.
+func (d *netgearDriver) FetchEventLog(ctx context.Context) ([]EventLog, error) {
+	page, err := d.getPage(ctx, d.conf.Url+"/EventLog.asp")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseNetgearEventLogs(d.logger, page, d.location)
+}
+
+// Logout is a no-op, there is no session to release.
+func (d *netgearDriver) Logout(ctx context.Context) error {
+	return nil
+}
+
+// getPage fetches the page at address once per poll, as the uptime
+// is read from /DocsisStatus.asp along with the connection status.
+func (d *netgearDriver) getPage(ctx context.Context, address string) (string, error) {
+	return fetchCachedPage(ctx, address, d.fetchPage)
+}
+
+func (d *netgearDriver) fetchPage(ctx context.Context, address string) (page string, err error) {
+	d.logger.Debug(fmt.Sprintf("grabbing %s", address),
+		zap.String("op", "scrape.getPage"),
+	)
+
+	start := time.Now()
+	defer func() { observePageFetch(address, start, err) }()
+
+	req, err := http.NewRequest("GET", address, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.SetBasicAuth(d.conf.Username, d.conf.Password)
+
+	resp, err := d.client.Do(req)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+	}
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	elapsed := time.Since(start)
+	d.logger.Debug(fmt.Sprintf("got %s, took %s", address, elapsed),
+		zap.String("op", "scrape.getPage"),
+	)
+
+	return string(bodyBytes), nil
+}
+
+func parseNetgearConnectionStatus(page string) *ConnectionStatus {
+	connectionStatus := ConnectionStatus{
+		StartupProcedure:         parseNetgearStartupProcedure(page),
+		DownstreamBondedChannels: parseNetgearDownstreamBondedChannels(page),
+		UpstreamBondedChannels:   parseNetgearUpstreamBondedChannels(page),
+	}
+
+	return &connectionStatus
+}
+
+func parseNetgearStartupProcedure(page string) StartupProcedure {
+	values := netgearTagValues(page, netgearTagValuePattern)
+	value := func(index int) string {
+		if index >= len(values) {
+			return ""
+		}
+		return values[index]
+	}
+
+	startupProcedure := StartupProcedure{
+		AcquireDownstreamChannel: Status{
+			Status:  withUnit(value(netgearAcquireDownstreamChannelStatus), "Hz"),
+			Comment: value(netgearAcquireDownstreamChannelComment),
+		},
+		ConnectivityState: Status{
+			Status:  value(netgearConnectivityStateStatus),
+			Comment: value(netgearConnectivityStateComment),
+		},
+		BootState: Status{
+			Status:  value(netgearBootStateStatus),
+			Comment: value(netgearBootStateComment),
+		},
+		ConfigurationFile: Status{
+			Status:  value(netgearConfigurationFileStatus),
+			Comment: value(netgearConfigurationFileComment),
+		},
+		Security: Status{
+			Status:  value(netgearSecurityStatus),
+			Comment: value(netgearSecurityComment),
+		},
+		DOCSISNetworkAccessEnabled: Status{
+			Status: value(netgearDOCSISNetworkAccessEnabledStatus),
+		},
+	}
+
+	return startupProcedure
+}
+
+// withUnit appends unit to value, unless value is missing.
+func withUnit(value string, unit string) string {
+	if value == "" {
+		return ""
+	}
+	return value + " " + unit
+}
+
+// "2|1|Locked|QAM256|1|345000000 Hz|4.9|40.4|41|0|2|Locked|..."
+// is the channel count followed by 9 fields per channel.
+func parseNetgearDownstreamBondedChannels(page string) []DownstreamBondedChannel {
+	const fieldsPerChannel = 9
+
+	downstreamBondedChannels := []DownstreamBondedChannel{}
+	for _, row := range netgearTableRows(netgearTagValues(page, netgearDsTableTagValuePattern), fieldsPerChannel) {
+		// Unused channel slots are reported with a zero channel ID.
+		if atoiField(row[3]) == 0 {
+			continue
+		}
+		downstreamBondedChannels = append(downstreamBondedChannels, DownstreamBondedChannel{
+			ChannelID:      atoiField(row[3]),
+			LockStatus:     row[1],
+			Modulation:     row[2],
+			FrequencyHz:    atoiField(row[4]),
+			PowerdBmV:      atofField(row[5]),
+			SNRdB:          atofField(row[6]),
+			Corrected:      atoiField(row[7]),
+			Uncorrectables: atoiField(row[8]),
+		})
+	}
+
+	return downstreamBondedChannels
+}
+
+// "2|1|Locked|ATDMA|1|5120 Ksym/sec|16400000 Hz|42.0 dBmV|2|..."
+// is the channel count followed by 7 fields per channel.
+func parseNetgearUpstreamBondedChannels(page string) []UpstreamBondedChannel {
+	const fieldsPerChannel = 7
+
+	upstreamBondedChannels := []UpstreamBondedChannel{}
+	for _, row := range netgearTableRows(netgearTagValues(page, netgearUsTableTagValuePattern), fieldsPerChannel) {
+		// Unused channel slots are reported with a zero channel ID.
+		if atoiField(row[3]) == 0 {
+			continue
+		}
+		upstreamBondedChannels = append(upstreamBondedChannels, UpstreamBondedChannel{
+			Channel:       atoiField(row[0]),
+			ChannelID:     atoiField(row[3]),
+			LockStatus:    row[1],
+			USChannelType: row[2],
+			FrequencyHz:   atoiField(row[5]),
+			WidthHz:       symbolRateToWidthHz(atoiField(row[4])),
+			PowerdBmV:     atofField(row[6]),
+		})
+	}
+
+	return upstreamBondedChannels
+}
+
+var netgearUptimePattern = regexp.MustCompile(`(?s)id="SystemUpTime".*?</b>([^<]*)<`)
+
+func parseNetgearSoftwareInformation(routerStatus string, docsisStatus string) *SoftwareInformation {
+	values := netgearTagValues(routerStatus, netgearTagValuePattern)
+	value := func(index int) string {
+		if index >= len(values) {
+			return ""
+		}
+		return values[index]
+	}
+
+	uptimeString := ""
+	if matches := netgearUptimePattern.FindStringSubmatch(docsisStatus); matches != nil {
+		uptimeString = strings.TrimSpace(matches[1])
+	}
+
+	softwareInformation := SoftwareInformation{
+		StandardSpecificationCompliant: value(netgearDOCSISMode),
+		HardwareVersion:                value(netgearHardwareVersion),
+		SoftwareVersion:                value(netgearFirmwareVersion),
+		MACAddress:                     value(netgearCableMACAddress),
+		SerialNumber:                   value(netgearSerialNumber),
+		UptimeMins:                     parseUptimeMinutes(uptimeString),
+		UptimeString:                   uptimeString,
+	}
+
+	return &softwareInformation
+}
+
+// netgearEventTable is the docsDevEventTable XML embedded
+// in /EventLog.asp.
+type netgearEventTable struct {
+	Events []struct {
+		FirstTime string `xml:"docsDevEvFirstTime"`
+		LastTime  string `xml:"docsDevEvLastTime"`
+		Counts    int    `xml:"docsDevEvCounts"`
+		Level     int    `xml:"docsDevEvLevel"`
+		ID        int    `xml:"docsDevEvId"`
+		Text      string `xml:"docsDevEvText"`
+	} `xml:"tr"`
+}
+
+var netgearEventTablePattern = regexp.MustCompile(`(?s)var\s+xmlFormat\s*=\s*'(.*?)';`)
+
+func parseNetgearEventLogs(logger *zap.Logger, page string, location *time.Location) ([]EventLog, error) {
+	eventLogs := []EventLog{}
+
+	matches := netgearEventTablePattern.FindStringSubmatch(page)
+	if matches == nil {
+		return eventLogs, nil
+	}
+
+	var table netgearEventTable
+	err := xml.Unmarshal([]byte(matches[1]), &table)
+	if err != nil {
+		observer.ObserveParseError(SectionEventLog)
+		return nil, fmt.Errorf("error parsing event log: %s", err.Error())
+	}
+
+	for _, event := range table.Events {
+		eventLogs = append(eventLogs, EventLog{
+			DateTime:    parseEventTime(logger, legacyDateTimeLayout, event.FirstTime, location),
+			RawDateTime: event.FirstTime,
+			EventID:     event.ID,
+			EventLevel:  event.Level,
+			Description: strings.TrimSpace(event.Text),
+		})
+	}
+
+	return eventLogs, nil
+}
+
+var (
+	netgearTagValuePattern        = netgearTagValueListPattern("InitTagValue")
+	netgearDsTableTagValuePattern = netgearTagValueListPattern("InitDsTableTagValue")
+	netgearUsTableTagValuePattern = netgearTagValueListPattern("InitUsTableTagValue")
+)
+
+// netgearTagValueListPattern matches the value assigned to
+// tagValueList within the named JavaScript function.
+func netgearTagValueListPattern(function string) *regexp.Regexp {
+	return regexp.MustCompile(`(?s)function\s+` + regexp.QuoteMeta(function) + `\s*\(\s*\)\s*\{.*?var\s+tagValueList\s*=\s*'([^']*)'`)
+}
+
+// netgearTagValues returns the "|" separated values assigned to
+// tagValueList, as matched by pattern.
+func netgearTagValues(page string, pattern *regexp.Regexp) []string {
+	matches := pattern.FindStringSubmatch(page)
+	if matches == nil {
+		return nil
+	}
+
+	values := strings.Split(matches[1], "|")
+	for i, value := range values {
+		values[i] = strings.TrimSpace(html.UnescapeString(value))
+	}
+
+	return values
+}
+
+// netgearTableRows splits a channel table's tag values, which begin
+// with the channel count, into rows of fieldsPerChannel values.
+func netgearTableRows(values []string, fieldsPerChannel int) [][]string {
+	rows := [][]string{}
+	if len(values) == 0 {
+		return rows
+	}
+
+	count := atoiField(values[0])
+	values = values[1:]
+	for i := 0; i < count && len(values) >= fieldsPerChannel; i++ {
+		rows = append(rows, values[:fieldsPerChannel])
+		values = values[fieldsPerChannel:]
+	}
+
+	return rows
+}
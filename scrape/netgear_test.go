@@ -0,0 +1,157 @@
+package scrape
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/janse180/modem-scraper/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func newNetgearTestServer(t *testing.T) *httptest.Server {
+	files := http.FileServer(http.Dir("../testdata/cm1000"))
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "admin" || password != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+}
+
+func TestNetgearDriver(t *testing.T) {
+	for _, model := range []string{"cm1000", "cm1200"} {
+		t.Run(model, func(t *testing.T) {
+			server := newNetgearTestServer(t)
+			defer server.Close()
+
+			driver, err := NewDriver(zap.NewNop(), config.Modem{
+				Model:    model,
+				Url:      server.URL,
+				Username: "admin",
+				Password: "password",
+				Timezone: "America/New_York",
+			})
+			assert.NoError(t, err)
+			assert.NoError(t, driver.Login(context.Background()))
+
+			connectionStatus, err := driver.FetchConnectionStatus(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, Status{Status: "579000000 Hz", Comment: "Locked"}, connectionStatus.StartupProcedure.AcquireDownstreamChannel)
+			assert.Equal(t, Status{Status: "OK", Comment: ""}, connectionStatus.StartupProcedure.ConfigurationFile)
+			assert.Equal(t, Status{Status: "Allowed"}, connectionStatus.StartupProcedure.DOCSISNetworkAccessEnabled)
+			assert.Len(t, connectionStatus.DownstreamBondedChannels, 32)
+			assert.Equal(t, DownstreamBondedChannel{
+				ChannelID:      1,
+				LockStatus:     "Locked",
+				Modulation:     "QAM256",
+				FrequencyHz:    345000000,
+				PowerdBmV:      4.9,
+				SNRdB:          40.4,
+				Corrected:      41,
+				Uncorrectables: 0,
+			}, connectionStatus.DownstreamBondedChannels[0])
+			assert.Len(t, connectionStatus.UpstreamBondedChannels, 4)
+			assert.Equal(t, UpstreamBondedChannel{
+				Channel:       1,
+				ChannelID:     1,
+				LockStatus:    "Locked",
+				USChannelType: "ATDMA",
+				FrequencyHz:   16400000,
+				WidthHz:       6400000,
+				PowerdBmV:     42.0,
+			}, connectionStatus.UpstreamBondedChannels[0])
+
+			softwareInformation, err := driver.FetchSoftwareInformation(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, &SoftwareInformation{
+				StandardSpecificationCompliant: "DOCSIS 3.1",
+				HardwareVersion:                "V2.02.03",
+				SoftwareVersion:                "V6.01.04",
+				MACAddress:                     "TH:IS:IS:FA:KE:02",
+				SerialNumber:                   "THISISFAKE1000",
+				UptimeMins:                     2278,
+				UptimeString:                   "37:58:48",
+			}, softwareInformation)
+
+			eventLogs, err := driver.FetchEventLog(context.Background())
+			assert.NoError(t, err)
+			assert.Len(t, eventLogs, 3)
+			assert.True(t, eventLogs[0].DateTime.IsZero())
+			assert.Equal(t, "Time Not Established", eventLogs[0].RawDateTime)
+			assert.Equal(t, "2019-10-11T03:12:09-04:00", eventLogs[1].DateTimeString())
+			assert.Equal(t, 82000200, eventLogs[0].EventID)
+			assert.Equal(t, 3, eventLogs[0].EventLevel)
+			assert.Equal(t, "SW Download INIT - Via NMS", eventLogs[2].Description)
+
+			assert.NoError(t, driver.Logout(context.Background()))
+		})
+	}
+}
+
+func TestNetgearSessionFetchesDocsisStatusOncePerPoll(t *testing.T) {
+	for _, parallelism := range []int{1, 3} {
+		var mu sync.Mutex
+		requests := map[string]int{}
+		files := http.FileServer(http.Dir("../testdata/cm1000"))
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			requests[r.URL.Path]++
+			mu.Unlock()
+			files.ServeHTTP(w, r)
+		}))
+
+		session, err := NewSession(zap.NewNop(), config.Modem{
+			Model:       "cm1000",
+			Url:         server.URL,
+			Parallelism: parallelism,
+		})
+		assert.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			modemInformation, err := session.Scrape(context.Background())
+			assert.NoError(t, err)
+			assert.Len(t, modemInformation.ConnectionStatus.DownstreamBondedChannels, 32)
+			assert.Equal(t, 2278, modemInformation.SoftwareInformation.UptimeMins)
+		}
+		server.Close()
+
+		mu.Lock()
+		assert.Equal(t, 2, requests["/DocsisStatus.asp"], "parallelism %d", parallelism)
+		assert.Equal(t, 2, requests["/RouterStatus.asp"], "parallelism %d", parallelism)
+		mu.Unlock()
+	}
+}
+
+func TestNetgearDriverWithBadCredentialsReturnsError(t *testing.T) {
+	server := newNetgearTestServer(t)
+	defer server.Close()
+
+	driver, err := NewDriver(zap.NewNop(), config.Modem{
+		Model:    "cm1000",
+		Url:      server.URL,
+		Username: "admin",
+		Password: "wrong",
+	})
+	assert.NoError(t, err)
+
+	_, err = driver.FetchConnectionStatus(context.Background())
+	assert.Error(t, err)
+}
+
+func TestParseNetgearStartupProcedureWithoutValues(t *testing.T) {
+	assert.Equal(t, StartupProcedure{}, parseNetgearStartupProcedure("<html></html>"))
+}
+
+func TestNetgearTableRows(t *testing.T) {
+	values := []string{"2", "a", "b", "c", "d", ""}
+	assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}}, netgearTableRows(values, 2))
+	assert.Equal(t, [][]string{}, netgearTableRows(nil, 2))
+	// A count larger than the data available must not overrun.
+	assert.Equal(t, [][]string{{"a", "b", "c"}}, netgearTableRows([]string{"3", "a", "b", "c", "d"}, 3))
+}
@@ -0,0 +1,48 @@
+package scrape
+
+import (
+	"net/url"
+	"time"
+)
+
+// Observer is notified as the modem is scraped, so that the health
+// of the scraper itself can be monitored, e.g. by package prom.
+type Observer interface {
+	// ObserveScrape is called once per Scrape with its total
+	// duration and result.
+	ObserveScrape(duration time.Duration, err error)
+	// ObservePageFetch is called for every page (or API call) a
+	// driver requests from the modem.
+	ObservePageFetch(page string, duration time.Duration, err error)
+	// ObserveLoginFailure is called when a driver fails to log in.
+	ObserveLoginFailure()
+	// ObserveParseError is called when part of a page can't be parsed.
+	ObserveParseError(section string)
+}
+
+type nopObserver struct{}
+
+func (nopObserver) ObserveScrape(time.Duration, error)            {}
+func (nopObserver) ObservePageFetch(string, time.Duration, error) {}
+func (nopObserver) ObserveLoginFailure()                          {}
+func (nopObserver) ObserveParseError(string)                      {}
+
+var observer Observer = nopObserver{}
+
+// SetObserver sets the Observer notified by all scrapes.
+func SetObserver(o Observer) {
+	if o == nil {
+		o = nopObserver{}
+	}
+	observer = o
+}
+
+// observePageFetch reports a page fetch by its path, which keeps
+// the modem's address out of the page name.
+func observePageFetch(address string, start time.Time, err error) {
+	page := address
+	if u, parseErr := url.Parse(address); parseErr == nil {
+		page = u.Path
+	}
+	observer.ObservePageFetch(page, time.Since(start), err)
+}
@@ -0,0 +1,70 @@
+package scrape
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/janse180/modem-scraper/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type recordingObserver struct {
+	scrapes       []error
+	pages         []string
+	loginFailures int
+}
+
+func (o *recordingObserver) ObserveScrape(duration time.Duration, err error) {
+	o.scrapes = append(o.scrapes, err)
+}
+
+func (o *recordingObserver) ObservePageFetch(page string, duration time.Duration, err error) {
+	o.pages = append(o.pages, page)
+}
+
+func (o *recordingObserver) ObserveLoginFailure() {
+	o.loginFailures++
+}
+
+func (o *recordingObserver) ObserveParseError(section string) {}
+
+func TestScrapeNotifiesObserver(t *testing.T) {
+	server := newNetgearTestServer(t)
+	defer server.Close()
+
+	o := &recordingObserver{}
+	SetObserver(o)
+	defer SetObserver(nil)
+
+	_, err := Scrape(context.Background(), zap.NewNop(), config.Configuration{Modem: config.Modem{
+		Model:    "cm1000",
+		Url:      server.URL,
+		Username: "admin",
+		Password: "password",
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, []error{nil}, o.scrapes)
+	assert.Equal(t, []string{"/DocsisStatus.asp", "/RouterStatus.asp", "/EventLog.asp"}, o.pages)
+}
+
+func TestScrapeNotifiesObserverOfLoginFailure(t *testing.T) {
+	server := newMB8600TestServer(t, "motorola")
+	defer server.Close()
+
+	o := &recordingObserver{}
+	SetObserver(o)
+	defer SetObserver(nil)
+
+	_, err := Scrape(context.Background(), zap.NewNop(), config.Configuration{Modem: config.Modem{
+		Model:    "mb8600",
+		Url:      server.URL,
+		Username: "admin",
+		Password: "wrong",
+	}})
+	assert.Error(t, err)
+	assert.Equal(t, 1, o.loginFailures)
+	assert.Len(t, o.scrapes, 1)
+	assert.Error(t, o.scrapes[0])
+}
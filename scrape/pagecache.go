@@ -0,0 +1,65 @@
+package scrape
+
+import (
+	"context"
+	"sync"
+)
+
+type pageCacheKey struct{}
+
+// pageCache holds the pages fetched during one poll, so that a page
+// which more than one section is read from is only fetched once.
+type pageCache struct {
+	mu    sync.Mutex
+	pages map[string]*cachedPage
+}
+
+// cachedPage is held while the page is being fetched, so that a
+// concurrent fetch of the same page waits for it rather than
+// fetching it again.
+type cachedPage struct {
+	mu      sync.Mutex
+	page    string
+	fetched bool
+}
+
+// withPageCache returns a context which caches the pages fetched
+// with it through fetchCachedPage until it is discarded.
+func withPageCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, pageCacheKey{}, &pageCache{pages: map[string]*cachedPage{}})
+}
+
+// fetchCachedPage returns the page at address from ctx's page cache,
+// fetching it if it isn't there yet. Failed fetches aren't cached,
+// so that retries fetch the page again. Without a page cache, the
+// page is always fetched.
+func fetchCachedPage(ctx context.Context, address string, fetch func(ctx context.Context, address string) (string, error)) (string, error) {
+	cache, ok := ctx.Value(pageCacheKey{}).(*pageCache)
+	if !ok {
+		return fetch(ctx, address)
+	}
+
+	cache.mu.Lock()
+	cached, ok := cache.pages[address]
+	if !ok {
+		cached = &cachedPage{}
+		cache.pages[address] = cached
+	}
+	cache.mu.Unlock()
+
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+
+	if cached.fetched {
+		return cached.page, nil
+	}
+
+	page, err := fetch(ctx, address)
+	if err != nil {
+		return "", err
+	}
+	cached.page = page
+	cached.fetched = true
+
+	return page, nil
+}
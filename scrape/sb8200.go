@@ -0,0 +1,272 @@
+package scrape
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/janse180/modem-scraper/config"
+	"go.uber.org/zap"
+)
+
+func init() {
+	RegisterDriver("sb8200", newSB8200Driver)
+}
+
+// sb8200Driver scrapes the Arris SB8200, which requires a
+// credential token obtained by logging in with basic auth.
+type sb8200Driver struct {
+	logger   *zap.Logger
+	conf     config.Modem
+	client   *http.Client
+	location *time.Location
+	token    string
+}
+
+func newSB8200Driver(logger *zap.Logger, conf config.Modem, client *http.Client, location *time.Location) Driver {
+	return &sb8200Driver{
+		logger:   logger,
+		conf:     conf,
+		client:   client,
+		location: location,
+	}
+}
+
+// Login obtains a new credential token from the modem.
+func (d *sb8200Driver) Login(ctx context.Context) error {
+	token, err := d.getToken(ctx)
+	if err != nil {
+		return err
+	}
+	d.token = token
+
+	return nil
+}
+
+// FetchConnectionStatus scrapes /cmconnectionstatus.html.
+func (d *sb8200Driver) FetchConnectionStatus(ctx context.Context) (*ConnectionStatus, error) {
+	doc, err := d.getDocumentFromURL(ctx, d.conf.Url+"/cmconnectionstatus.html")
+	if err != nil {
+		return nil, err
+	}
+
+	return scrapeConnectionStatus(doc), nil
+}
+
+// FetchSoftwareInformation scrapes /cmswinfo.html.
+func (d *sb8200Driver) FetchSoftwareInformation(ctx context.Context) (*SoftwareInformation, error) {
+	doc, err := d.getDocumentFromURL(ctx, d.conf.Url+"/cmswinfo.html")
+	if err != nil {
+		return nil, err
+	}
+
+	return scrapeSoftwareInformation(doc), nil
+}
+
+// FetchEventLog scrapes /cmeventlog.html.
+func (d *sb8200Driver) FetchEventLog(ctx context.Context) ([]EventLog, error) {
+	doc, err := d.getDocumentFromURL(ctx, d.conf.Url+"/cmeventlog.html")
+	if err != nil {
+		return nil, err
+	}
+
+	return scrapeEventLogs(d.logger, doc, d.location), nil
+}
+
+// Logout lets the modem reclaim resources, per https://github.com/mdonoughe/modem_status
+func (d *sb8200Driver) Logout(ctx context.Context) error {
+	_, err := d.getDocumentFromURL(ctx, d.conf.Url+"/logout.html")
+	d.token = ""
+
+	// Logging out lands on the login page.
+	if err == ErrSessionExpired {
+		return nil
+	}
+	return err
+}
+
+// Reboot restarts the modem by submitting the form behind the
+// "Restart Cable Modem" button on /cmconfiguration.html.
+func (d *sb8200Driver) Reboot(ctx context.Context) error {
+	address := d.conf.Url + "/cmconfiguration.html"
+	doc, err := d.getDocumentFromURL(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	form := doc.Find("form").FilterFunction(func(_ int, form *goquery.Selection) bool {
+		return form.Find("input[name=Rebooting]").Length() > 0
+	}).First()
+	if form.Length() == 0 {
+		return fmt.Errorf("reboot form not found on %s", address)
+	}
+
+	values := url.Values{}
+	form.Find("input[name]").Each(func(_ int, input *goquery.Selection) {
+		name, _ := input.Attr("name")
+		value, _ := input.Attr("value")
+		values.Set(name, value)
+	})
+	values.Set("Rebooting", "1")
+
+	base, err := url.Parse(address)
+	if err != nil {
+		return err
+	}
+	action, _ := form.Attr("action")
+	target, err := base.Parse(action)
+	if err != nil {
+		return fmt.Errorf("invalid reboot form action %q: %s", action, err.Error())
+	}
+
+	d.logger.Info("rebooting modem",
+		zap.String("op", "scrape.Reboot"),
+	)
+
+	return d.postForm(ctx, target.String(), values)
+}
+
+func (d *sb8200Driver) postForm(ctx context.Context, address string, values url.Values) (err error) {
+	start := time.Now()
+	defer func() { observePageFetch(address, start, err) }()
+
+	req, err := http.NewRequest("POST", address, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(d.conf.Username, d.conf.Password)
+	req.AddCookie(&http.Cookie{Name: "credential", Value: d.token})
+
+	resp, err := d.client.Do(req)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return ErrSessionExpired
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+	}
+
+	return nil
+}
+
+func (d *sb8200Driver) getDocumentFromURL(ctx context.Context, address string) (doc *goquery.Document, err error) {
+	d.logger.Debug(fmt.Sprintf("grabbing %s", address),
+		zap.String("op", "scrape.getDocumentFromURL"),
+	)
+
+	start := time.Now()
+	defer func() { observePageFetch(address, start, err) }()
+
+	req, err := http.NewRequest("GET", address, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.SetBasicAuth(d.conf.Username, d.conf.Password)
+	req.AddCookie(&http.Cookie{Name: "credential", Value: d.token})
+
+	resp, err := d.client.Do(req)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrSessionExpired
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+	}
+	// An expired token is redirected to, or answered with, the login page.
+	if resp.Request.URL.Path != req.URL.Path {
+		return nil, ErrSessionExpired
+	}
+
+	doc, err = goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if isSB8200LoginPage(doc) {
+		return nil, ErrSessionExpired
+	}
+
+	elapsed := time.Since(start)
+	d.logger.Debug(fmt.Sprintf("got %s, took %s", address, elapsed),
+		zap.String("op", "scrape.getDocumentFromURL"),
+	)
+
+	// logger.Debug(fmt.Sprintf("%s", doc.Text()),
+	// 	zap.String("op", "scrape.getDocumentFromURL"),
+	// )
+
+	return doc, nil
+}
+
+func (d *sb8200Driver) getToken(ctx context.Context) (token string, err error) {
+
+	d.logger.Info("Attempting to renew token",
+		zap.String("op", "scrape.getToken"),
+	)
+	start := time.Now()
+	defer func() { observePageFetch(d.conf.Url+"/cmconnectionstatus.html", start, err) }()
+
+	authString := d.conf.Username + ":" + d.conf.Password
+	basicAuthString := base64.StdEncoding.EncodeToString([]byte(authString))
+
+	req, err := http.NewRequest("GET", d.conf.Url+"/cmconnectionstatus.html?"+basicAuthString, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.SetBasicAuth(d.conf.Username, d.conf.Password)
+
+	resp, err := d.client.Do(req)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		// The error includes the URL, whose query is the credentials.
+		if urlErr, ok := err.(*url.Error); ok {
+			urlErr.URL = d.conf.Url + "/cmconnectionstatus.html"
+		}
+		return "", err
+	}
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+	}
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	token = string(bodyBytes)
+	if len(token) != 31 {
+		return "", fmt.Errorf("did not retrieve auth token successfully")
+	}
+
+	// The token is a credential, so it is never logged.
+	d.logger.Debug("Got new token",
+		zap.String("op", "scrape.getToken"),
+	)
+
+	return token, nil
+}
+
+func isSB8200LoginPage(doc *goquery.Document) bool {
+	return doc.Find("input[type=password]").Length() > 0
+}
@@ -0,0 +1,47 @@
+package scrape
+
+import "strings"
+
+// The sections of a scrape, each fetched from its own page.
+const (
+	SectionConnectionStatus    = "connection_status"
+	SectionSoftwareInformation = "software_information"
+	SectionEventLog            = "event_log"
+)
+
+// SectionError is the failure to scrape one section.
+type SectionError struct {
+	Section string
+	Err     error
+}
+
+func (e SectionError) Error() string {
+	return e.Section + ": " + e.Err.Error()
+}
+
+// ScrapeError lists the sections that could not be scraped. It is
+// returned alongside a partially populated ModemInformation when
+// only some sections failed.
+type ScrapeError struct {
+	Sections []SectionError
+}
+
+func (e *ScrapeError) Error() string {
+	failures := make([]string, len(e.Sections))
+	for i, section := range e.Sections {
+		failures[i] = section.Error()
+	}
+
+	return "failed to scrape " + strings.Join(failures, "; ")
+}
+
+// Failed reports whether section could not be scraped.
+func (e *ScrapeError) Failed(section string) bool {
+	for _, failure := range e.Sections {
+		if failure.Section == section {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,334 @@
+package scrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/janse180/modem-scraper/config"
+	"go.uber.org/zap"
+)
+
+// ErrSessionExpired is returned by a Driver when the modem no longer
+// accepts its login, e.g. it answers 401 or redirects to the login
+// page. A Session logs in again when it sees this error.
+var ErrSessionExpired = errors.New("modem session expired")
+
+// ErrRebootNotSupported is returned by Session.Reboot when the
+// modem model's Driver can't reboot it.
+var ErrRebootNotSupported = errors.New("rebooting is not supported for this modem model")
+
+const (
+	defaultAttempts     = 3
+	defaultRetryBackoff = time.Second
+	maxRetryBackoff     = 30 * time.Second
+	defaultParallelism  = 1
+)
+
+// Session scrapes the modem with a Driver, logging in once and
+// reusing the login across polls. Some modems, the SB8200 among
+// them, lock up their web sessions when logged into too often.
+// Sessions are safe for concurrent use; scrapes are serialized.
+type Session struct {
+	mu           sync.Mutex
+	logger       *zap.Logger
+	driver       Driver
+	loginPerPoll bool
+	attempts     int
+	retryBackoff time.Duration
+	parallelism  int
+
+	// loginMu is held for reading by every fetch, and for writing
+	// while logging in again, so that a driver's login never changes
+	// under a request in flight. generation counts the logins, so
+	// that concurrent fetches which all find the session expired
+	// only log in again once.
+	loginMu    sync.RWMutex
+	loggedIn   bool
+	generation int
+}
+
+// NewSession creates a Session for the configured modem model.
+// No login happens until the first Scrape.
+func NewSession(logger *zap.Logger, conf config.Modem) (*Session, error) {
+	driver, err := NewDriver(logger, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := conf.Attempts
+	if attempts <= 0 {
+		attempts = defaultAttempts
+	}
+	retryBackoff := conf.RetryBackoff
+	if retryBackoff <= 0 {
+		retryBackoff = defaultRetryBackoff
+	}
+	parallelism := conf.Parallelism
+	if parallelism <= 0 {
+		parallelism = defaultParallelism
+	}
+
+	return &Session{
+		logger:       logger,
+		driver:       driver,
+		loginPerPoll: conf.LoginPerPoll,
+		attempts:     attempts,
+		retryBackoff: retryBackoff,
+		parallelism:  parallelism,
+	}, nil
+}
+
+// Scrape scrapes data from the modem, logging in first if there is
+// no session yet, and again if the session has expired. Once logged
+// in, up to the configured parallelism of pages are fetched at once.
+// Each page is retried on failure; if some sections still fail, the
+// sections that succeeded are returned along with a *ScrapeError.
+// Cancelling ctx abandons the scrape.
+func (s *Session) Scrape(ctx context.Context) (modemInformation *ModemInformation, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := time.Now()
+	defer func() { observer.ObserveScrape(time.Since(start), err) }()
+
+	s.loginMu.Lock()
+	err = s.login(ctx)
+	s.loginMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if s.loginPerPoll {
+		// Logout to let the modem reclaim resources, even when a page
+		// fails or ctx is cancelled.
+		defer s.logout(context.Background())
+	}
+
+	// Pages read by more than one section are only fetched once.
+	ctx = withPageCache(ctx)
+
+	modemInformation = &ModemInformation{}
+	fetches := []struct {
+		section string
+		fetch   func(ctx context.Context) error
+	}{
+		{SectionConnectionStatus, func(ctx context.Context) error {
+			connectionStatus, err := s.driver.FetchConnectionStatus(ctx)
+			if err == nil {
+				modemInformation.ConnectionStatus = *connectionStatus
+			}
+			return err
+		}},
+		{SectionSoftwareInformation, func(ctx context.Context) error {
+			softwareInformation, err := s.driver.FetchSoftwareInformation(ctx)
+			if err == nil {
+				modemInformation.SoftwareInformation = *softwareInformation
+			}
+			return err
+		}},
+		{SectionEventLog, func(ctx context.Context) error {
+			eventLog, err := s.driver.FetchEventLog(ctx)
+			if err == nil {
+				for i := range eventLog {
+					eventLog[i] = eventLog[i].Decode()
+				}
+				modemInformation.EventLog = eventLog
+			}
+			return err
+		}},
+	}
+
+	// Each fetch only sets its own section of modemInformation, and
+	// its own result. Fetches start in order as slots free up.
+	results := make([]error, len(fetches))
+	slots := make(chan struct{}, s.parallelism)
+	var wg sync.WaitGroup
+	for i, f := range fetches {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, section string, fetch func(ctx context.Context) error) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			results[i] = s.retry(ctx, section, recoverFetch(section, fetch))
+		}(i, f.section, f.fetch)
+	}
+	wg.Wait()
+
+	scrapeErr := &ScrapeError{}
+	for i, f := range fetches {
+		if results[i] != nil {
+			scrapeErr.Sections = append(scrapeErr.Sections, SectionError{Section: f.section, Err: results[i]})
+			modemInformation.FailedSections = append(modemInformation.FailedSections, f.section)
+		}
+	}
+
+	if len(scrapeErr.Sections) == len(fetches) {
+		return nil, scrapeErr
+	}
+	if len(scrapeErr.Sections) > 0 {
+		return modemInformation, scrapeErr
+	}
+
+	return modemInformation, nil
+}
+
+// Reboot restarts the modem, if its Driver supports it, logging in
+// first if need be. It waits for a scrape in progress to finish.
+func (s *Session) Reboot(ctx context.Context) error {
+	rebooter, ok := s.driver.(Rebooter)
+	if !ok {
+		return ErrRebootNotSupported
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loginMu.Lock()
+	err := s.login(ctx)
+	s.loginMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	err = s.withLogin(ctx, rebooter.Reboot)
+
+	// The modem forgets its logins when it restarts.
+	s.loginMu.Lock()
+	s.loggedIn = false
+	s.loginMu.Unlock()
+
+	return err
+}
+
+// Close logs out of the modem, if logged in. It waits for a
+// scrape in progress to finish first.
+func (s *Session) Close(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.logout(ctx)
+}
+
+// retry runs fetch until it succeeds, s.attempts is reached or ctx
+// is cancelled, backing off exponentially between attempts.
+func (s *Session) retry(ctx context.Context, section string, fetch func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := s.withLogin(ctx, fetch)
+		if err == nil || attempt >= s.attempts || ctx.Err() != nil {
+			return err
+		}
+
+		delay := backoff(s.retryBackoff, attempt)
+		s.logger.Warn(fmt.Sprintf("failed to scrape %s, retrying in %s", section, delay),
+			zap.String("op", "scrape.Session"),
+			zap.Int("attempt", attempt),
+			zap.Error(err),
+		)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// recoverFetch turns a panic in fetch, e.g. from a parser given a
+// page without the table it expects, into an error, so that a bad
+// page fails its section rather than the whole process.
+func recoverFetch(section string, fetch func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				observer.ObserveParseError(section)
+				err = fmt.Errorf("error parsing %s: %v", section, r)
+			}
+		}()
+
+		return fetch(ctx)
+	}
+}
+
+// backoff returns the delay after the given attempt: base doubled
+// for each earlier attempt, capped at maxRetryBackoff, with up to
+// half of it random so retries don't land in lockstep.
+func backoff(base time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// withLogin runs fetch, logging in again and retrying once if the
+// session turns out to have expired.
+func (s *Session) withLogin(ctx context.Context, fetch func(ctx context.Context) error) error {
+	s.loginMu.RLock()
+	generation := s.generation
+	err := fetch(ctx)
+	s.loginMu.RUnlock()
+	if err != ErrSessionExpired {
+		return err
+	}
+
+	s.loginMu.Lock()
+	// Another fetch may have logged in again already.
+	if s.generation == generation {
+		s.logger.Info("modem session expired, logging in again",
+			zap.String("op", "scrape.Session"),
+		)
+		s.loggedIn = false
+		err = s.login(ctx)
+	} else {
+		err = nil
+	}
+	s.loginMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.loginMu.RLock()
+	defer s.loginMu.RUnlock()
+
+	return fetch(ctx)
+}
+
+// login logs in if not already logged in. s.loginMu must be held
+// for writing.
+func (s *Session) login(ctx context.Context) error {
+	if s.loggedIn {
+		return nil
+	}
+
+	err := s.driver.Login(ctx)
+	if err != nil {
+		observer.ObserveLoginFailure()
+		return err
+	}
+	s.loggedIn = true
+	s.generation++
+
+	return nil
+}
+
+func (s *Session) logout(ctx context.Context) error {
+	s.loginMu.Lock()
+	defer s.loginMu.Unlock()
+
+	if !s.loggedIn {
+		return nil
+	}
+	s.loggedIn = false
+
+	return s.driver.Logout(ctx)
+}
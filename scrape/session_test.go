@@ -0,0 +1,426 @@
+package scrape
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/janse180/modem-scraper/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	zapobserver "go.uber.org/zap/zaptest/observer"
+)
+
+const sb8200LoginPage = `<html><body><form><input type="text" id="username"><input type="password" id="password"></form></body></html>`
+
+// sb8200TestServer emulates the SB8200 credential token login,
+// answering with the login page when the token is unknown.
+type sb8200TestServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	token    string
+	logins   int
+	logouts  int
+	failures map[string]int
+	pages    map[string]string
+	reboots  []url.Values
+}
+
+func newSB8200TestServer(t *testing.T) *sb8200TestServer {
+	s := &sb8200TestServer{failures: map[string]int{}, pages: map[string]string{}}
+	files := http.FileServer(http.Dir("../testdata/sb8200"))
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if r.URL.RawQuery == base64.StdEncoding.EncodeToString([]byte("admin:password")) {
+			s.logins++
+			s.token = strings.Repeat(string('a'+rune(s.logins%26)), 31)
+			w.Write([]byte(s.token))
+			return
+		}
+
+		cookie, err := r.Cookie("credential")
+		if err != nil || s.token == "" || cookie.Value != s.token {
+			w.Write([]byte(sb8200LoginPage))
+			return
+		}
+
+		if s.failures[r.URL.Path] > 0 {
+			s.failures[r.URL.Path]--
+			http.Error(w, "busy", http.StatusInternalServerError)
+			return
+		}
+
+		if page, ok := s.pages[r.URL.Path]; ok {
+			w.Write([]byte(page))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/goform/cmconfiguration":
+			r.ParseForm()
+			s.reboots = append(s.reboots, r.PostForm)
+			s.token = ""
+		case "/logout.html":
+			s.logouts++
+			s.token = ""
+			w.Write([]byte(sb8200LoginPage))
+		case "/cmeventlog.html":
+			w.Write([]byte("<html><body></body></html>"))
+		default:
+			files.ServeHTTP(w, r)
+		}
+	}))
+
+	return s
+}
+
+// expire forgets the current token, as the modem does on a timeout.
+func (s *sb8200TestServer) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = ""
+}
+
+// fail makes the next n requests for path fail.
+func (s *sb8200TestServer) fail(path string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = n
+}
+
+// serve answers requests for path with page.
+func (s *sb8200TestServer) serve(path string, page string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pages[path] = page
+}
+
+func (s *sb8200TestServer) counts() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins, s.logouts
+}
+
+func newTestSession(t *testing.T, url string, loginPerPoll bool) *Session {
+	session, err := NewSession(zap.NewNop(), config.Modem{
+		Url:          url,
+		Username:     "admin",
+		Password:     "password",
+		LoginPerPoll: loginPerPoll,
+		RetryBackoff: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unable to create session: %s", err)
+	}
+
+	return session
+}
+
+func TestSessionReusesLoginAcrossPolls(t *testing.T) {
+	server := newSB8200TestServer(t)
+	defer server.Close()
+	session := newTestSession(t, server.URL, false)
+
+	for i := 0; i < 3; i++ {
+		modemInformation, err := session.Scrape(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, modemInformation.ConnectionStatus.DownstreamBondedChannels, 32)
+	}
+
+	logins, logouts := server.counts()
+	assert.Equal(t, 1, logins)
+	assert.Equal(t, 0, logouts)
+
+	assert.NoError(t, session.Close(context.Background()))
+	_, logouts = server.counts()
+	assert.Equal(t, 1, logouts)
+}
+
+func TestSessionLogsInAgainWhenExpired(t *testing.T) {
+	server := newSB8200TestServer(t)
+	defer server.Close()
+	session := newTestSession(t, server.URL, false)
+
+	_, err := session.Scrape(context.Background())
+	assert.NoError(t, err)
+
+	server.expire()
+
+	modemInformation, err := session.Scrape(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "THISISFAKE12345", modemInformation.SoftwareInformation.SerialNumber)
+
+	logins, _ := server.counts()
+	assert.Equal(t, 2, logins)
+}
+
+func TestSessionWithLoginPerPollLogsInAndOutEveryPoll(t *testing.T) {
+	server := newSB8200TestServer(t)
+	defer server.Close()
+	session := newTestSession(t, server.URL, true)
+
+	for i := 0; i < 2; i++ {
+		_, err := session.Scrape(context.Background())
+		assert.NoError(t, err)
+	}
+
+	logins, logouts := server.counts()
+	assert.Equal(t, 2, logins)
+	assert.Equal(t, 2, logouts)
+}
+
+func TestSessionNeverLogsCredentials(t *testing.T) {
+	server := newSB8200TestServer(t)
+	core, logs := zapobserver.New(zapcore.DebugLevel)
+	session, err := NewSession(zap.New(core), config.Modem{
+		Url:          server.URL,
+		Username:     "admin",
+		Password:     "password",
+		RetryBackoff: time.Millisecond,
+	})
+	assert.NoError(t, err)
+
+	_, err = session.Scrape(context.Background())
+	assert.NoError(t, err)
+
+	// Once the modem is unreachable, the errors don't give the
+	// credentials away either.
+	server.Close()
+	session, err = NewSession(zap.New(core), config.Modem{
+		Url:          server.URL,
+		Username:     "admin",
+		Password:     "password",
+		RetryBackoff: time.Millisecond,
+	})
+	assert.NoError(t, err)
+	_, err = session.Scrape(context.Background())
+	assert.Error(t, err)
+
+	credentials := base64.StdEncoding.EncodeToString([]byte("admin:password"))
+	assert.NotContains(t, err.Error(), credentials)
+	server.mu.Lock()
+	token := server.token
+	server.mu.Unlock()
+	assert.NotEmpty(t, token)
+	for _, entry := range logs.AllUntimed() {
+		line := entry.Message
+		for _, value := range entry.ContextMap() {
+			line += fmt.Sprint(value)
+		}
+		assert.NotContains(t, line, token)
+		assert.NotContains(t, line, credentials)
+	}
+}
+
+func TestSessionRetriesFailedPages(t *testing.T) {
+	server := newSB8200TestServer(t)
+	defer server.Close()
+	session := newTestSession(t, server.URL, false)
+
+	server.fail("/cmswinfo.html", defaultAttempts-1)
+
+	modemInformation, err := session.Scrape(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "THISISFAKE12345", modemInformation.SoftwareInformation.SerialNumber)
+	assert.Empty(t, modemInformation.FailedSections)
+}
+
+func TestSessionReturnsPartialModemInformation(t *testing.T) {
+	server := newSB8200TestServer(t)
+	defer server.Close()
+	session := newTestSession(t, server.URL, false)
+
+	server.fail("/cmeventlog.html", defaultAttempts)
+
+	modemInformation, err := session.Scrape(context.Background())
+	assert.Error(t, err)
+	assert.NotNil(t, modemInformation)
+	assert.Len(t, modemInformation.ConnectionStatus.DownstreamBondedChannels, 32)
+	assert.Equal(t, "THISISFAKE12345", modemInformation.SoftwareInformation.SerialNumber)
+	assert.Equal(t, []string{SectionEventLog}, modemInformation.FailedSections)
+	assert.False(t, modemInformation.Scraped(SectionEventLog))
+	assert.True(t, modemInformation.Scraped(SectionConnectionStatus))
+
+	scrapeErr, ok := err.(*ScrapeError)
+	if assert.True(t, ok) {
+		assert.Len(t, scrapeErr.Sections, 1)
+		assert.True(t, scrapeErr.Failed(SectionEventLog))
+		assert.Contains(t, scrapeErr.Error(), "event_log: status code error: 500")
+	}
+}
+
+func TestSessionRecoversFromParserPanics(t *testing.T) {
+	server := newSB8200TestServer(t)
+	defer server.Close()
+	session := newTestSession(t, server.URL, false)
+
+	// An event log row with an empty date cell.
+	server.serve("/cmeventlog.html", `<html><body><div id="bg3"><div class="container"><div class="content"><form><center><table>
+<tr><th>Event Log</th></tr>
+<tr><td></td><td></td><td></td><td></td></tr>
+</table></center></form></div></div></div></body></html>`)
+	server.serve("/cmswinfo.html", "<html><body></body></html>")
+
+	modemInformation, err := session.Scrape(context.Background())
+	assert.Error(t, err)
+	assert.NotNil(t, modemInformation)
+	assert.Equal(t, []string{SectionEventLog}, modemInformation.FailedSections)
+	assert.Equal(t, 0, modemInformation.SoftwareInformation.UptimeMins)
+	assert.Len(t, modemInformation.ConnectionStatus.DownstreamBondedChannels, 32)
+}
+
+func TestSessionReturnsNothingWhenEverySectionFails(t *testing.T) {
+	server := newSB8200TestServer(t)
+	defer server.Close()
+	session := newTestSession(t, server.URL, false)
+
+	server.fail("/cmconnectionstatus.html", defaultAttempts)
+	server.fail("/cmswinfo.html", defaultAttempts)
+	server.fail("/cmeventlog.html", defaultAttempts)
+
+	modemInformation, err := session.Scrape(context.Background())
+	assert.Error(t, err)
+	assert.Nil(t, modemInformation)
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		delay := backoff(time.Second, attempt+1)
+		assert.True(t, delay >= expected/2 && delay <= expected, "attempt %d: %s", attempt+1, delay)
+	}
+	assert.True(t, backoff(time.Second, 20) <= maxRetryBackoff)
+}
+
+// newSlowSB6183TestServer serves the SB6183 pages after delay,
+// recording the most requests it saw in flight at once.
+func newSlowSB6183TestServer(t *testing.T, delay time.Duration) (*httptest.Server, *int32) {
+	var inFlight, maxInFlight int32
+	files := http.FileServer(http.Dir("../testdata/sb6183"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+
+	return server, &maxInFlight
+}
+
+func TestSessionFetchesPagesConcurrently(t *testing.T) {
+	for _, parallelism := range []int{0, 1, 3} {
+		server, maxInFlight := newSlowSB6183TestServer(t, 50*time.Millisecond)
+		session, err := NewSession(zap.NewNop(), config.Modem{
+			Model:       "sb6183",
+			Url:         server.URL,
+			Parallelism: parallelism,
+		})
+		assert.NoError(t, err)
+
+		modemInformation, err := session.Scrape(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "THISISFAKE6183", modemInformation.SoftwareInformation.SerialNumber)
+		// Events are decoded as they are scraped.
+		assert.Equal(t, "critical", modemInformation.EventLog[0].Severity)
+		assert.Equal(t, "th:is:is:fa:ke:00", modemInformation.EventLog[0].CMMAC)
+
+		expected := int32(parallelism)
+		if parallelism == 0 {
+			expected = defaultParallelism
+		}
+		assert.Equal(t, expected, atomic.LoadInt32(maxInFlight), "parallelism %d", parallelism)
+		server.Close()
+	}
+}
+
+func TestSessionScrapeIsCancelledWithContext(t *testing.T) {
+	server, _ := newSlowSB6183TestServer(t, time.Minute)
+	defer server.Close()
+	session, err := NewSession(zap.NewNop(), config.Modem{
+		Model:       "sb6183",
+		Url:         server.URL,
+		Parallelism: 3,
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	modemInformation, err := session.Scrape(ctx)
+	assert.Error(t, err)
+	assert.Nil(t, modemInformation)
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestConcurrentSessionLogsInAgainOnceWhenExpired(t *testing.T) {
+	server := newSB8200TestServer(t)
+	defer server.Close()
+	session, err := NewSession(zap.NewNop(), config.Modem{
+		Url:         server.URL,
+		Username:    "admin",
+		Password:    "password",
+		Parallelism: 3,
+	})
+	assert.NoError(t, err)
+
+	_, err = session.Scrape(context.Background())
+	assert.NoError(t, err)
+
+	server.expire()
+
+	modemInformation, err := session.Scrape(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, modemInformation.FailedSections)
+
+	logins, _ := server.counts()
+	assert.Equal(t, 2, logins)
+}
+
+func TestSessionRebootsSB8200(t *testing.T) {
+	server := newSB8200TestServer(t)
+	defer server.Close()
+	session := newTestSession(t, server.URL, false)
+
+	assert.NoError(t, session.Reboot(context.Background()))
+
+	server.mu.Lock()
+	assert.Len(t, server.reboots, 1)
+	assert.Equal(t, "1", server.reboots[0].Get("Rebooting"))
+	assert.Equal(t, "0x00", server.reboots[0].Get("RestoreFactoryNo"))
+	server.mu.Unlock()
+
+	// The reboot ends the session, so the next scrape logs in again.
+	_, err := session.Scrape(context.Background())
+	assert.NoError(t, err)
+	logins, _ := server.counts()
+	assert.Equal(t, 2, logins)
+}
+
+func TestSessionRebootIsNotSupportedByEveryModel(t *testing.T) {
+	session, err := NewSession(zap.NewNop(), config.Modem{Model: "sb6183", Url: "http://192.168.100.1"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, ErrRebootNotSupported, session.Reboot(context.Background()))
+}
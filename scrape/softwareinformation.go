@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	_ "github.com/influxdata/influxdb1-client" // this is important because of a bug in go mod
@@ -23,21 +22,29 @@ type SoftwareInformation struct {
 }
 
 // ToInfluxPoints converts SoftwareInformation to "points"
-func (s SoftwareInformation) ToInfluxPoints() ([]*client.Point, error) {
+func (s SoftwareInformation) ToInfluxPoints(options InfluxOptions) ([]*client.Point, error) {
 	var points []*client.Point
 
-	// No tags for this specific struct.
-	tags := map[string]string{}
-	fields := map[string]interface{}{
+	descriptions := map[string]string{
 		"standard_specification_compliant": s.StandardSpecificationCompliant,
 		"hardware_version":                 s.HardwareVersion,
 		"software_version":                 s.SoftwareVersion,
 		"mac_address":                      s.MACAddress,
 		"serial_number":                    s.SerialNumber,
-		"uptime_mins":                      s.UptimeMins,
-		"uptime_string":                    s.UptimeString,
 	}
-	point, err := client.NewPoint("software_information", tags, fields, time.Now())
+	tags := map[string]string{}
+	fields := map[string]interface{}{
+		"uptime_mins":   s.UptimeMins,
+		"uptime_string": s.UptimeString,
+	}
+	for key, value := range descriptions {
+		if options.Schema == InfluxSchemaV2 {
+			tags[key] = value
+		} else {
+			fields[key] = value
+		}
+	}
+	point, err := client.NewPoint("software_information", tags, fields, options.Time)
 	if err != nil {
 		return nil, fmt.Errorf("error generating points data for SoftwareInformation: %s", err.Error())
 	}
@@ -72,13 +79,22 @@ func scrapeSoftwareInformation(doc *goquery.Document) *SoftwareInformation {
 
 // "0 days 02h:44m:31s.00"
 func uptimeToMinutes(uptime string) int {
-	fmt.Printf("%s", uptime)
-	daysString := strings.Split(uptime, " ")[0]
-	timeString := strings.Split(uptime, " ")[2]
+	// A page without the uptime, e.g. one served mid-reboot,
+	// gives an uptime of 0 rather than a panic.
+	fields := strings.Split(uptime, " ")
+	if len(fields) < 3 {
+		return 0
+	}
+	daysString := fields[0]
+	timeString := fields[2]
 	timeString = strings.ReplaceAll(timeString, "h", "")
 	timeString = strings.ReplaceAll(timeString, "m", "")
-	hoursString := strings.Split(timeString, ":")[0]
-	minutesString := strings.Split(timeString, ":")[1]
+	timeFields := strings.Split(timeString, ":")
+	if len(timeFields) < 2 {
+		return 0
+	}
+	hoursString := timeFields[0]
+	minutesString := timeFields[1]
 
 	days, _ := strconv.Atoi(daysString)
 	hours, _ := strconv.Atoi(hoursString)
@@ -29,6 +29,12 @@ func TestUptimeToMinutesWith1d23h59mReturns2879(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestUptimeToMinutesWithMissingUptimeReturns0(t *testing.T) {
+	assert.Equal(t, 0, uptimeToMinutes(""))
+	assert.Equal(t, 0, uptimeToMinutes("1 days"))
+	assert.Equal(t, 0, uptimeToMinutes("1 days 23h"))
+}
+
 func TestScrapeSoftwareInformation(t *testing.T) {
 	doc := getSoftwareInformationDocumentFromTestFile(t)
 
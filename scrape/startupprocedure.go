@@ -2,7 +2,6 @@ package scrape
 
 import (
 	"fmt"
-	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	_ "github.com/influxdata/influxdb1-client" // this is important because of a bug in go mod
@@ -27,27 +26,46 @@ type Status struct {
 	Comment string
 }
 
+// okStatuses are the startup procedure statuses or comments which
+// mean a step succeeded.
+var okStatuses = map[string]bool{
+	"OK":      true,
+	"Locked":  true,
+	"Allowed": true,
+	"Enabled": true,
+}
+
+// OK reports whether a startup procedure step succeeded. The result
+// is in Status for most steps, but the downstream channel has its
+// frequency there and "Locked" in the Comment.
+func (s Status) OK() bool {
+	return okStatuses[s.Status] || okStatuses[s.Comment]
+}
+
 // ToInfluxPoints converts StartupProcedure to "points"
-func (s StartupProcedure) ToInfluxPoints() ([]*client.Point, error) {
+func (s StartupProcedure) ToInfluxPoints(options InfluxOptions) ([]*client.Point, error) {
 	var points []*client.Point
 
+	steps := map[string]Status{
+		"acquire_downstream_channel":    s.AcquireDownstreamChannel,
+		"connectivity_state":            s.ConnectivityState,
+		"boot_state":                    s.BootState,
+		"configuration_file":            s.ConfigurationFile,
+		"security":                      s.Security,
+		"docsis_network_access_enabled": s.DOCSISNetworkAccessEnabled,
+	}
+
 	// No tags for this specific struct.
 	tags := map[string]string{}
-	fields := map[string]interface{}{
-		"acquire_downstream_channel_status":     s.AcquireDownstreamChannel.Status,
-		"acquire_downstream_channel_comment":    s.AcquireDownstreamChannel.Comment,
-		"connectivity_state_status":             s.ConnectivityState.Status,
-		"connectivity_state_comment":            s.ConnectivityState.Comment,
-		"boot_state_status":                     s.BootState.Status,
-		"boot_state_comment":                    s.BootState.Comment,
-		"configuration_file_status":             s.ConfigurationFile.Status,
-		"configuration_file_comment":            s.ConfigurationFile.Comment,
-		"security_status":                       s.Security.Status,
-		"security_comment":                      s.Security.Comment,
-		"docsis_network_access_enabled_status":  s.DOCSISNetworkAccessEnabled.Status,
-		"docsis_network_access_enabled_comment": s.DOCSISNetworkAccessEnabled.Comment,
+	fields := map[string]interface{}{}
+	for step, status := range steps {
+		fields[step+"_status"] = status.Status
+		fields[step+"_comment"] = status.Comment
+		if options.Schema == InfluxSchemaV2 {
+			fields[step+"_ok"] = okField(status.OK())
+		}
 	}
-	point, err := client.NewPoint("startup_procedure", tags, fields, time.Now())
+	point, err := client.NewPoint("startup_procedure", tags, fields, options.Time)
 	if err != nil {
 		return nil, fmt.Errorf("error generating points data for StartupProcedure: %s", err.Error())
 	}
@@ -4,12 +4,10 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	_ "github.com/influxdata/influxdb1-client" // this is important because of a bug in go mod
 	client "github.com/influxdata/influxdb1-client/v2"
-	"github.com/prometheus/client_golang/prometheus"
 )
 
 // UpstreamBondedChannel holds all info from the
@@ -25,36 +23,8 @@ type UpstreamBondedChannel struct {
 	PowerdBmV     float64
 }
 
-var (
-	UpstreamBondedChannelPowerGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "upstream_bonded_channel_powerdbmv",
-		Help: "The upstream bonded channel power",
-	}, []string{
-		"Channel",
-		"ChannelID",
-		"LockStatus",
-		"USChannelType",
-		"FrequencyHz",
-		"WidthHz",
-	})
-)
-
-func (u UpstreamBondedChannel) UpdateGauge() error {
-
-	UpstreamBondedChannelPowerGauge.WithLabelValues(
-		strconv.Itoa(u.Channel),
-		strconv.Itoa(u.ChannelID),
-		u.LockStatus,
-		u.USChannelType,
-		strconv.Itoa(u.FrequencyHz),
-		strconv.Itoa(u.WidthHz)).Set(u.PowerdBmV)
-
-	return nil
-
-}
-
 // ToInfluxPoints converts UpstreamBondedChannel to "points"
-func (u UpstreamBondedChannel) ToInfluxPoints() ([]*client.Point, error) {
+func (u UpstreamBondedChannel) ToInfluxPoints(options InfluxOptions) ([]*client.Point, error) {
 	var points []*client.Point
 
 	channelString := strconv.Itoa(u.Channel)
@@ -64,13 +34,19 @@ func (u UpstreamBondedChannel) ToInfluxPoints() ([]*client.Point, error) {
 		"channel_id": channelIDString,
 	}
 	fields := map[string]interface{}{
-		"lock_status":     u.LockStatus,
-		"us_channel_type": u.USChannelType,
-		"frequency_hz":    u.FrequencyHz,
-		"width_hz":        u.WidthHz,
-		"power_dbmv":      u.PowerdBmV,
+		"frequency_hz": u.FrequencyHz,
+		"width_hz":     u.WidthHz,
+		"power_dbmv":   u.PowerdBmV,
+	}
+	if options.Schema == InfluxSchemaV2 {
+		tags["lock_status"] = u.LockStatus
+		tags["us_channel_type"] = u.USChannelType
+		fields["lock_status_ok"] = okField(u.LockStatus == "Locked")
+	} else {
+		fields["lock_status"] = u.LockStatus
+		fields["us_channel_type"] = u.USChannelType
 	}
-	point, err := client.NewPoint("upstream_bonded_channel", tags, fields, time.Now())
+	point, err := client.NewPoint("upstream_bonded_channel", tags, fields, options.Time)
 	if err != nil {
 		return nil, fmt.Errorf("error generating points data for UpstreamBondedChannel: %s", err.Error())
 	}